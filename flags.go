@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"github.com/angelhvargas/dell-disk-exporter/pkg/config"
+)
+
+// parseFlags builds the configuration from the file given with -config.file
+// and the command-line flags, which take precedence over the file. It also
+// reports whether -config.check was given.
+func parseFlags(args []string) (*config.Config, bool, error) {
+	fs := flag.NewFlagSet("dell-disk-exporter", flag.ContinueOnError)
+	configFile := fs.String("config.file", "", "Path to the YAML configuration file")
+	configCheck := fs.Bool("config.check", false, "Validate the configuration and exit")
+
+	// overrides applies the flags given on the command line on top of the file.
+	defaults := config.Default()
+	overrides := make(map[string]func(*config.Config))
+	stringFlag := func(name, value, usage string, set func(*config.Config, string)) {
+		v := fs.String(name, value, usage)
+		overrides[name] = func(c *config.Config) { set(c, *v) }
+	}
+	boolFlag := func(name string, value bool, usage string, set func(*config.Config, bool)) {
+		v := fs.Bool(name, value, usage)
+		overrides[name] = func(c *config.Config) { set(c, *v) }
+	}
+	intFlag := func(name string, value int, usage string, set func(*config.Config, int)) {
+		v := fs.Int(name, value, usage)
+		overrides[name] = func(c *config.Config) { set(c, *v) }
+	}
+	durationFlag := func(name string, value time.Duration, usage string, set func(*config.Config, time.Duration)) {
+		v := fs.Duration(name, value, usage)
+		overrides[name] = func(c *config.Config) { set(c, *v) }
+	}
+
+	stringFlag("web.listen-address", defaults.Web.ListenAddress, "Address to listen on",
+		func(c *config.Config, v string) { c.Web.ListenAddress = v })
+	stringFlag("web.telemetry-path", defaults.Web.MetricsPath, "Path under which to expose metrics",
+		func(c *config.Config, v string) { c.Web.MetricsPath = v })
+	durationFlag("collector.min-interval", defaults.Collectors.IDRAC.MinInterval, "Minimum time between two collections of every collector; scrapes within this interval are served from the previous results",
+		func(c *config.Config, v time.Duration) {
+			c.Collectors.IDRAC.MinInterval = v
+			c.Collectors.SMART.MinInterval = v
+			c.Collectors.Smartctl.MinInterval = v
+		})
+
+	idrac := defaults.Collectors.IDRAC
+	boolFlag("collector.idrac", idrac.Enabled, "Enable the iDRAC RAID collector",
+		func(c *config.Config, v bool) { c.Collectors.IDRAC.Enabled = v })
+	stringFlag("idrac.source", idrac.Source, "Backend used to read the iDRAC storage inventory: racadm or redfish",
+		func(c *config.Config, v string) { c.Collectors.IDRAC.Source = v })
+	durationFlag("idrac.timeout", idrac.Timeout, "Timeout of each racadm command or Redfish request",
+		func(c *config.Config, v time.Duration) { c.Collectors.IDRAC.Timeout = v })
+	durationFlag("idrac.absent-grace-period", idrac.AbsentGracePeriod, "How long the series of a removed virtual disk are kept",
+		func(c *config.Config, v time.Duration) { c.Collectors.IDRAC.AbsentGracePeriod = v })
+	stringFlag("idrac.racadm-path", idrac.RacadmPath, "Path to the racadm binary",
+		func(c *config.Config, v string) { c.Collectors.IDRAC.RacadmPath = v })
+	stringFlag("idrac.redfish.endpoint", idrac.Redfish.Endpoint, "Base URL of the iDRAC Redfish API, e.g. https://idrac.example.com",
+		func(c *config.Config, v string) { c.Collectors.IDRAC.Redfish.Endpoint = v })
+	stringFlag("idrac.redfish.username", idrac.Redfish.Username, "Username for the iDRAC Redfish API; the password is read from IDRAC_REDFISH_PASSWORD",
+		func(c *config.Config, v string) { c.Collectors.IDRAC.Redfish.Username = v })
+	boolFlag("idrac.redfish.insecure", idrac.Redfish.InsecureSkipVerify, "Skip TLS certificate verification for the iDRAC Redfish API",
+		func(c *config.Config, v bool) { c.Collectors.IDRAC.Redfish.InsecureSkipVerify = v })
+
+	smart := defaults.Collectors.SMART
+	boolFlag("collector.smart", smart.Enabled, "Enable the NVMe SMART collector",
+		func(c *config.Config, v bool) { c.Collectors.SMART.Enabled = v })
+	durationFlag("smart.timeout", smart.Timeout, "Timeout of each nvme command",
+		func(c *config.Config, v time.Duration) { c.Collectors.SMART.Timeout = v })
+	durationFlag("smart.absent-grace-period", smart.AbsentGracePeriod, "How long the series of a removed NVMe drive are kept",
+		func(c *config.Config, v time.Duration) { c.Collectors.SMART.AbsentGracePeriod = v })
+	stringFlag("smart.nvme-path", smart.NVMePath, "Path to the nvme binary",
+		func(c *config.Config, v string) { c.Collectors.SMART.NVMePath = v })
+	stringFlag("smart.sysfs-root", smart.SysfsRoot, "Mount point of sysfs, read to discover the NVMe devices",
+		func(c *config.Config, v string) { c.Collectors.SMART.SysfsRoot = v })
+	boolFlag("smart.legacy-smart-log", smart.LegacySMARTLog, "Also export the untyped nvme_smart_log{device,metric} gauge",
+		func(c *config.Config, v bool) { c.Collectors.SMART.LegacySMARTLog = v })
+	boolFlag("smart.self-test", smart.SelfTest.Enabled, "Start short self-tests on the NVMe drives on -smart.self-test.schedule",
+		func(c *config.Config, v bool) { c.Collectors.SMART.SelfTest.Enabled = v })
+	stringFlag("smart.self-test.schedule", smart.SelfTest.Schedule, "Cron expression, in local time, of the NVMe short self-tests",
+		func(c *config.Config, v string) { c.Collectors.SMART.SelfTest.Schedule = v })
+	intFlag("smart.self-test.concurrency", smart.SelfTest.Concurrency, "Number of NVMe drives self-tested at once",
+		func(c *config.Config, v int) { c.Collectors.SMART.SelfTest.Concurrency = v })
+	durationFlag("smart.self-test.timeout", smart.SelfTest.Timeout, "How long a self-test may run before the next drive is tested",
+		func(c *config.Config, v time.Duration) { c.Collectors.SMART.SelfTest.Timeout = v })
+
+	smartctl := defaults.Collectors.Smartctl
+	boolFlag("collector.smartctl", smartctl.Enabled, "Enable the SATA/SAS collector reading drives with smartctl",
+		func(c *config.Config, v bool) { c.Collectors.Smartctl.Enabled = v })
+	durationFlag("smartctl.timeout", smartctl.Timeout, "Timeout of each smartctl command",
+		func(c *config.Config, v time.Duration) { c.Collectors.Smartctl.Timeout = v })
+	stringFlag("smartctl.path", smartctl.SmartctlPath, "Path to the smartctl binary",
+		func(c *config.Config, v string) { c.Collectors.Smartctl.SmartctlPath = v })
+	boolFlag("smartctl.megaraid", smartctl.MegaRAID, "Also read the drives behind PERC/MegaRAID controllers with smartctl -d megaraid,N",
+		func(c *config.Config, v bool) { c.Collectors.Smartctl.MegaRAID = v })
+
+	if err := fs.Parse(args); err != nil {
+		return nil, false, err
+	}
+
+	conf := config.Default()
+	if *configFile != "" {
+		var err error
+		if conf, err = config.Load(*configFile); err != nil {
+			return nil, *configCheck, err
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if override, ok := overrides[f.Name]; ok {
+			override(conf)
+		}
+	})
+	if conf.Collectors.IDRAC.Redfish.Password == "" {
+		conf.Collectors.IDRAC.Redfish.Password = os.Getenv("IDRAC_REDFISH_PASSWORD")
+	}
+
+	return conf, *configCheck, conf.Validate()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseFlagsDefaults(t *testing.T) {
+	conf, check, err := parseFlags(nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if check {
+		t.Fatal("Expected -config.check to be unset")
+	}
+	if conf.Web.ListenAddress != ":9077" || conf.Web.MetricsPath != "/metrics" {
+		t.Fatalf("Unexpected web config %+v", conf.Web)
+	}
+	if conf.Collectors.IDRAC.Timeout != 60*time.Second || conf.Collectors.SMART.AbsentGracePeriod != 5*time.Minute {
+		t.Fatalf("Unexpected collectors config %+v", conf.Collectors)
+	}
+}
+
+func TestParseFlagsOverrideFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := `
+web:
+  listen_address: ":9100"
+collectors:
+  idrac:
+    timeout: 30s
+  smart:
+    enabled: false
+    nvme_path: /usr/local/sbin/nvme
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Error writing config: %v", err)
+	}
+
+	conf, check, err := parseFlags([]string{"-config.file", path, "-config.check", "-idrac.timeout=10s", "-collector.min-interval=1m"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !check {
+		t.Fatal("Expected -config.check to be set")
+	}
+	if conf.Web.ListenAddress != ":9100" {
+		t.Errorf("Expected listen address from the file, got %q", conf.Web.ListenAddress)
+	}
+	if conf.Collectors.IDRAC.Timeout != 10*time.Second {
+		t.Errorf("Expected the flag to override the idrac timeout, got %s", conf.Collectors.IDRAC.Timeout)
+	}
+	if conf.Collectors.SMART.Enabled || conf.Collectors.SMART.NVMePath != "/usr/local/sbin/nvme" {
+		t.Errorf("Unexpected smart config %+v", conf.Collectors.SMART)
+	}
+	if conf.Collectors.SMART.SysfsRoot != "/sys" {
+		t.Errorf("Expected the default sysfs root, got %q", conf.Collectors.SMART.SysfsRoot)
+	}
+	if conf.Collectors.IDRAC.MinInterval != time.Minute || conf.Collectors.SMART.MinInterval != time.Minute {
+		t.Errorf("Expected a 1m min interval, got %+v", conf.Collectors)
+	}
+}
+
+func TestParseFlagsInvalid(t *testing.T) {
+	tests := [][]string{
+		{"-idrac.source=ipmi"},
+		{"-idrac.source=redfish"},
+		{"-smart.timeout=0s"},
+		{"-web.telemetry-path=metrics"},
+		{"-config.file=/nonexistent/config.yml"},
+	}
+
+	for _, args := range tests {
+		if _, _, err := parseFlags(args); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
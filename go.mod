@@ -2,7 +2,10 @@ module github.com/angelhvargas/dell-disk-exporter
 
 go 1.21.4
 
-require github.com/prometheus/client_golang v1.19.1
+require (
+	github.com/prometheus/client_golang v1.19.1
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/beorn7/perks v1.0.1 // indirect
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
-	"time"
+	"os"
 
 	"github.com/angelhvargas/dell-disk-exporter/pkg/idrac"
 	"github.com/angelhvargas/dell-disk-exporter/pkg/smart"
@@ -13,6 +16,18 @@ import (
 )
 
 func main() {
+	conf, check, err := parseFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if check {
+		log.Println("Configuration is valid")
+		return
+	}
+
 	// Create a new Prometheus registry
 	registry := prometheus.NewRegistry()
 
@@ -22,20 +37,77 @@ func main() {
 		collectors.NewGoCollector(),
 	)
 
-	// Start the Prometheus metrics server
-	go func() {
-		http.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
-		log.Fatal(http.ListenAndServe(":9077", nil))
-	}()
+	idracConf := conf.Collectors.IDRAC
+	idracExecutor := &idrac.DefaultCommandExecutor{
+		Timeout: idracConf.Timeout,
+		Paths:   map[string]string{"racadm": idracConf.RacadmPath},
+	}
+	var idracSource idrac.Source
+	if idracConf.Enabled {
+		// Initialize the IDRAC client with the selected backend and registry
+		idracSource = idrac.NewRacadmSource(idracExecutor)
+		if idracConf.Source == "redfish" {
+			idracSource = idrac.NewRedfishSource(idrac.RedfishConfig{
+				Endpoint:           idracConf.Redfish.Endpoint,
+				Username:           idracConf.Redfish.Username,
+				Password:           idracConf.Redfish.Password,
+				InsecureSkipVerify: idracConf.Redfish.InsecureSkipVerify,
+				Timeout:            idracConf.Timeout,
+			})
+		}
+		idracClient := idrac.NewClientWithSource(idracSource, registry, idracConf.AbsentGracePeriod)
+		idracClient.MinInterval = idracConf.MinInterval
+	}
+
+	smartConf := conf.Collectors.SMART
+	if smartConf.Enabled {
+		// Initialize the SMART metrics collector with the configured binaries and registry
+		smartExecutor := &smart.DefaultCommandExecutor{
+			Timeout: smartConf.Timeout,
+			Paths:   map[string]string{"nvme": smartConf.NVMePath},
+		}
+		smartMetrics := smart.NewMetrics(smartExecutor, registry, smartConf.AbsentGracePeriod)
+		smartMetrics.SysfsRoot = smartConf.SysfsRoot
+		smartMetrics.MinInterval = smartConf.MinInterval
+		smartMetrics.LegacySMARTLog = smartConf.LegacySMARTLog
+		smartMetrics.ApprovedFirmware = smartConf.ApprovedFirmware
+		http.HandleFunc("/debug/nvme/error-log", smartMetrics.ServeErrorLog)
 
-	// Initialize the IDRAC client with the default executor and registry
-	idracClient := idrac.NewClient(&idrac.DefaultCommandExecutor{}, registry)
-	// Start the update loop
-	go idracClient.UpdateMetrics()
+		if selfTestConf := smartConf.SelfTest; selfTestConf.Enabled {
+			// The schedule was checked when the configuration was validated
+			schedule, err := smart.ParseSchedule(selfTestConf.Schedule)
+			if err != nil {
+				log.Fatalf("Invalid self-test schedule: %v", err)
+			}
+			scheduler := smart.NewSelfTestScheduler(smartExecutor, registry, schedule, selfTestConf.Concurrency)
+			scheduler.Timeout = selfTestConf.Timeout
+			scheduler.SysfsRoot = smartConf.SysfsRoot
+			go scheduler.Run(context.Background())
+		}
+	}
 
-	// Initialize the SMART metrics updater with the default executor and registry
-	smartMetrics := smart.NewMetrics(&smart.DefaultCommandExecutor{}, registry, 5*time.Minute)
-	go smartMetrics.UpdateMetrics()
+	smartctlConf := conf.Collectors.Smartctl
+	if smartctlConf.Enabled {
+		smartctlExecutor := &smart.DefaultCommandExecutor{
+			Timeout: smartctlConf.Timeout,
+			Paths:   map[string]string{"smartctl": smartctlConf.SmartctlPath},
+		}
+		smartctlMetrics := smart.NewSmartctlMetrics(smartctlExecutor, registry)
+		smartctlMetrics.MinInterval = smartctlConf.MinInterval
+		smartctlMetrics.MegaRAID = smartctlConf.MegaRAID
+		if smartctlConf.MegaRAID && idracSource != nil {
+			// Label the drives behind the PERC controllers with their racadm pdisk
+			smartctlMetrics.PDisks = func() (map[string]string, error) {
+				return idrac.PDiskSerials(idracSource)
+			}
+		}
+	}
 
-	select {} // Block forever
+	// Start the Prometheus metrics server, metrics are collected on scrape
+	http.Handle(conf.Web.MetricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	http.HandleFunc("/probe", func(w http.ResponseWriter, r *http.Request) {
+		probeHandler(w, r, conf, idracExecutor)
+	})
+	log.Printf("Listening on %s", conf.Web.ListenAddress)
+	log.Fatal(http.ListenAndServe(conf.Web.ListenAddress, nil))
 }
@@ -26,7 +26,7 @@ func (e *MockCommandExecutor) ExecuteCommand(name string, args ...string) ([]byt
 }
 
 // mockGetNVMeDrives simulates the function to detect NVMe drives for testing
-var mockGetNVMeDrives = func() ([]string, error) {
+var mockGetNVMeDrives = func(string) ([]string, error) {
 	return []string{"nvme0n1"}, nil
 }
 
@@ -79,61 +79,42 @@ Disk.Virtual.0:RAID.Integrated.1-0
 
 	// Set up SMART metrics
 	smartRegistry := prometheus.NewRegistry()
-	smartMetrics := smart.NewMetrics(mockExecutor, smartRegistry, 5*time.Minute)
+	smart.NewMetrics(mockExecutor, smartRegistry, 5*time.Minute)
 	originalGetNVMeDrives := smart.GetNVMeDrives
 	smart.GetNVMeDrives = mockGetNVMeDrives
 	defer func() { smart.GetNVMeDrives = originalGetNVMeDrives }()
 
-	go smartMetrics.UpdateMetrics()
-
 	// Set up RAID metrics
 	raidRegistry := prometheus.NewRegistry()
-	raidClient := idrac.NewClient(mockRAIDExecutor, raidRegistry)
-	go raidClient.UpdateMetrics()
-
-	// Allow some time for metrics to be updated
-	time.Sleep(1 * time.Second)
+	idrac.NewClient(mockRAIDExecutor, raidRegistry, 5*time.Minute)
 
 	// Test SMART log metrics
 	expectedSmartMetrics := `
-# HELP nvme_smart_log SMART log metrics for NVMe devices
-# TYPE nvme_smart_log gauge
-nvme_smart_log{device="nvme0n1",metric="avail_spare"} 100
-nvme_smart_log{device="nvme0n1",metric="controller_busy_time"} 1.974546e+06
-nvme_smart_log{device="nvme0n1",metric="critical_comp_time"} 0
-nvme_smart_log{device="nvme0n1",metric="critical_warning"} 0
-nvme_smart_log{device="nvme0n1",metric="data_units_read"} 4.99296134e+08
-nvme_smart_log{device="nvme0n1",metric="data_units_written"} 1.474968593e+09
-nvme_smart_log{device="nvme0n1",metric="host_read_commands"} 9.347931143e+09
-nvme_smart_log{device="nvme0n1",metric="host_write_commands"} 5.1493840602e+10
-nvme_smart_log{device="nvme0n1",metric="media_errors"} 0
-nvme_smart_log{device="nvme0n1",metric="num_err_log_entries"} 17
-nvme_smart_log{device="nvme0n1",metric="percent_used"} 15
-nvme_smart_log{device="nvme0n1",metric="power_cycles"} 290
-nvme_smart_log{device="nvme0n1",metric="power_on_hours"} 38313
-nvme_smart_log{device="nvme0n1",metric="spare_thresh"} 5
-nvme_smart_log{device="nvme0n1",metric="temperature"} 301
-nvme_smart_log{device="nvme0n1",metric="temperature_sensor_1"} 306
-nvme_smart_log{device="nvme0n1",metric="temperature_sensor_2"} 301
-nvme_smart_log{device="nvme0n1",metric="temperature_sensor_3"} 296
-nvme_smart_log{device="nvme0n1",metric="temperature_sensor_4"} 295
-nvme_smart_log{device="nvme0n1",metric="thm_temp1_total_time"} 0
-nvme_smart_log{device="nvme0n1",metric="thm_temp1_trans_count"} 0
-nvme_smart_log{device="nvme0n1",metric="thm_temp2_total_time"} 0
-nvme_smart_log{device="nvme0n1",metric="thm_temp2_trans_count"} 0
-nvme_smart_log{device="nvme0n1",metric="unsafe_shutdowns"} 139
-nvme_smart_log{device="nvme0n1",metric="warning_temp_time"} 0
+# HELP nvme_data_units_written_bytes_total Bytes written by the host
+# TYPE nvme_data_units_written_bytes_total counter
+nvme_data_units_written_bytes_total{device="nvme0n1"} 7.55183919616e+14
+# HELP nvme_percentage_used_ratio Vendor estimate of the used endurance, may exceed 1
+# TYPE nvme_percentage_used_ratio gauge
+nvme_percentage_used_ratio{device="nvme0n1"} 0.15
+# HELP nvme_temperature_celsius Temperature of the controller in degrees Celsius, by sensor (composite or the sensor number)
+# TYPE nvme_temperature_celsius gauge
+nvme_temperature_celsius{device="nvme0n1",sensor="1"} 33
+nvme_temperature_celsius{device="nvme0n1",sensor="2"} 28
+nvme_temperature_celsius{device="nvme0n1",sensor="3"} 23
+nvme_temperature_celsius{device="nvme0n1",sensor="4"} 22
+nvme_temperature_celsius{device="nvme0n1",sensor="composite"} 28
 `
-	if err := testutil.GatherAndCompare(smartRegistry, strings.NewReader(expectedSmartMetrics), "nvme_smart_log"); err != nil {
+	if err := testutil.GatherAndCompare(smartRegistry, strings.NewReader(expectedSmartMetrics),
+		"nvme_data_units_written_bytes_total", "nvme_percentage_used_ratio", "nvme_temperature_celsius"); err != nil {
 		t.Fatalf("unexpected collecting result:\n%s", err)
 	}
 
 	// Test RAID status metrics
 	expectedRaidStatus := `
-# HELP raid_status Status of the RAID controller
+# HELP raid_status Status of the RAID virtual disk (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)
 # TYPE raid_status gauge
-raid_status{vdisk="RAID.Integrated.1-1"} 1
-raid_status{vdisk="RAID.Integrated.1-0"} 1
+raid_status{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1
+raid_status{vdisk="RAID.Integrated.1-0",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-0"} 1
 `
 	if err := testutil.GatherAndCompare(raidRegistry, strings.NewReader(expectedRaidStatus), "raid_status"); err != nil {
 		t.Fatalf("unexpected collecting result:\n%s", err)
@@ -143,8 +124,8 @@ raid_status{vdisk="RAID.Integrated.1-0"} 1
 	expectedRaidRedundancy := `
 # HELP raid_redundancy Remaining redundancy of the RAID controller
 # TYPE raid_redundancy gauge
-raid_redundancy{vdisk="RAID.Integrated.1-1"} 1
-raid_redundancy{vdisk="RAID.Integrated.1-0"} 1
+raid_redundancy{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1
+raid_redundancy{vdisk="RAID.Integrated.1-0",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-0"} 1
 `
 	if err := testutil.GatherAndCompare(raidRegistry, strings.NewReader(expectedRaidRedundancy), "raid_redundancy"); err != nil {
 		t.Fatalf("unexpected collecting result:\n%s", err)
@@ -152,23 +133,23 @@ raid_redundancy{vdisk="RAID.Integrated.1-0"} 1
 
 	// Test RAID size metrics
 	expectedRaidSize := `
-# HELP raid_size Size of the RAID controller
-# TYPE raid_size gauge
-raid_size{vdisk="RAID.Integrated.1-1"} 1787.5
-raid_size{vdisk="RAID.Integrated.1-0"} 372
+# HELP raid_vdisk_size_bytes Size of the RAID virtual disk in bytes
+# TYPE raid_vdisk_size_bytes gauge
+raid_vdisk_size_bytes{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1.9193135104e+12
+raid_vdisk_size_bytes{vdisk="RAID.Integrated.1-0",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-0"} 3.99431958528e+11
 `
-	if err := testutil.GatherAndCompare(raidRegistry, strings.NewReader(expectedRaidSize), "raid_size"); err != nil {
+	if err := testutil.GatherAndCompare(raidRegistry, strings.NewReader(expectedRaidSize), "raid_vdisk_size_bytes"); err != nil {
 		t.Fatalf("unexpected collecting result:\n%s", err)
 	}
 
-	// Test RAID layout metrics
-	expectedRaidLayout := `
-# HELP raid_layout Layout of the RAID controller
-# TYPE raid_layout gauge
-raid_layout{vdisk="RAID.Integrated.1-1"} 1
-raid_layout{vdisk="RAID.Integrated.1-0"} 1
+	// Test RAID level metrics
+	expectedRaidLevel := `
+# HELP raid_vdisk_level RAID level of the virtual disk derived from its layout
+# TYPE raid_vdisk_level gauge
+raid_vdisk_level{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 10
+raid_vdisk_level{vdisk="RAID.Integrated.1-0",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-0"} 1
 `
-	if err := testutil.GatherAndCompare(raidRegistry, strings.NewReader(expectedRaidLayout), "raid_layout"); err != nil {
+	if err := testutil.GatherAndCompare(raidRegistry, strings.NewReader(expectedRaidLevel), "raid_vdisk_level"); err != nil {
 		t.Fatalf("unexpected collecting result:\n%s", err)
 	}
 }
@@ -0,0 +1,321 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/angelhvargas/dell-disk-exporter/pkg/smart"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the exporter configuration file. Command-line flags override it.
+type Config struct {
+	Web        WebConfig        `yaml:"web"`
+	Collectors CollectorsConfig `yaml:"collectors"`
+	// Modules holds the settings used by /probe, selected with its module parameter.
+	Modules map[string]Module `yaml:"modules"`
+}
+
+// WebConfig configures the HTTP server.
+type WebConfig struct {
+	ListenAddress string `yaml:"listen_address"`
+	MetricsPath   string `yaml:"metrics_path"`
+}
+
+// CollectorsConfig configures the collectors of the local host.
+type CollectorsConfig struct {
+	IDRAC    IDRACConfig    `yaml:"idrac"`
+	SMART    SMARTConfig    `yaml:"smart"`
+	Smartctl SmartctlConfig `yaml:"smartctl"`
+}
+
+// IDRACConfig configures the iDRAC RAID collector.
+type IDRACConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Source is the backend used to read the storage inventory, racadm or redfish.
+	Source string `yaml:"source"`
+	// MinInterval is the minimum time between two collections, see idrac.Client.
+	MinInterval time.Duration `yaml:"min_interval"`
+	// Timeout bounds each racadm command or Redfish request.
+	Timeout time.Duration `yaml:"timeout"`
+	// AbsentGracePeriod is how long the series of a removed virtual disk are kept.
+	AbsentGracePeriod time.Duration `yaml:"absent_grace_period"`
+	RacadmPath        string        `yaml:"racadm_path"`
+	Redfish           RedfishConfig `yaml:"redfish"`
+}
+
+// RedfishConfig configures access to the local iDRAC over Redfish.
+type RedfishConfig struct {
+	Endpoint string `yaml:"endpoint"`
+	Username string `yaml:"username"`
+	// Password defaults to the IDRAC_REDFISH_PASSWORD environment variable.
+	Password           string `yaml:"password"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// SMARTConfig configures the NVMe SMART collector.
+type SMARTConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MinInterval is the minimum time between two collections, see smart.Metrics.
+	MinInterval time.Duration `yaml:"min_interval"`
+	// Timeout bounds each nvme command.
+	Timeout time.Duration `yaml:"timeout"`
+	// AbsentGracePeriod is how long the series of a removed drive are kept.
+	AbsentGracePeriod time.Duration `yaml:"absent_grace_period"`
+	NVMePath          string        `yaml:"nvme_path"`
+	// SysfsRoot is where sysfs is mounted, read to discover the NVMe devices.
+	SysfsRoot string `yaml:"sysfs_root"`
+	// LegacySMARTLog also exports the untyped nvme_smart_log gauge, see smart.Metrics.
+	LegacySMARTLog bool           `yaml:"legacy_smart_log"`
+	SelfTest       SelfTestConfig `yaml:"self_test"`
+	// ApprovedFirmware lists the approved firmware revisions by drive model,
+	// as reported by nvme id-ctrl. Exported as nvme_firmware_approved when set.
+	ApprovedFirmware map[string][]string `yaml:"approved_firmware"`
+}
+
+// SelfTestConfig configures the short self-tests started on the NVMe drives,
+// see smart.SelfTestScheduler.
+type SelfTestConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Schedule is a cron expression in local time, see smart.ParseSchedule.
+	Schedule string `yaml:"schedule"`
+	// Concurrency is the number of drives tested at once.
+	Concurrency int `yaml:"concurrency"`
+	// Timeout is how long a test may run before the next drive is tested.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// SmartctlConfig configures the SATA/SAS collector reading drives with smartctl.
+type SmartctlConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MinInterval is the minimum time between two collections, see smart.SmartctlMetrics.
+	MinInterval time.Duration `yaml:"min_interval"`
+	// Timeout bounds each smartctl command.
+	Timeout      time.Duration `yaml:"timeout"`
+	SmartctlPath string        `yaml:"smartctl_path"`
+	// MegaRAID also reads the physical drives behind PERC controllers and
+	// labels them with their racadm pdisk when the iDRAC collector is enabled.
+	MegaRAID bool `yaml:"megaraid"`
+}
+
+// Module describes how to reach the iDRACs probed with it.
+type Module struct {
+	// Source is the backend used to query the target, redfish (default) or racadm.
+	Source   string `yaml:"source"`
+	Username string `yaml:"username"`
+	// Password is passed on the racadm command line with the racadm source,
+	// where local users can read it from the process list.
+	Password string `yaml:"password"`
+	// InsecureSkipVerify disables TLS certificate verification for Redfish.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+	// Timeout bounds each Redfish request, 60 seconds by default.
+	Timeout time.Duration `yaml:"timeout"`
+	// Targets lists the host names, IP addresses and CIDR networks the module
+	// may be used with. The credentials of the module are never sent elsewhere.
+	Targets []string `yaml:"targets"`
+}
+
+// Allows reports whether host, a host name or IP address without port, is
+// listed in the targets of the module. Host names are compared as given and
+// never resolved.
+func (m Module) Allows(host string) bool {
+	ip := net.ParseIP(host)
+	for _, target := range m.Targets {
+		if _, network, err := net.ParseCIDR(target); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if targetIP := net.ParseIP(target); targetIP != nil {
+			if targetIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(strings.TrimSuffix(target, "."), strings.TrimSuffix(host, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Default returns the configuration used when no file is given.
+func Default() *Config {
+	return &Config{
+		Web: WebConfig{
+			ListenAddress: ":9077",
+			MetricsPath:   "/metrics",
+		},
+		Collectors: CollectorsConfig{
+			IDRAC: IDRACConfig{
+				Enabled:           true,
+				Source:            "racadm",
+				MinInterval:       60 * time.Second,
+				Timeout:           60 * time.Second,
+				AbsentGracePeriod: 5 * time.Minute,
+				RacadmPath:        "racadm",
+				Redfish: RedfishConfig{
+					Username: "root",
+				},
+			},
+			SMART: SMARTConfig{
+				Enabled:           true,
+				MinInterval:       60 * time.Second,
+				Timeout:           60 * time.Second,
+				AbsentGracePeriod: 5 * time.Minute,
+				NVMePath:          "nvme",
+				SysfsRoot:         "/sys",
+				SelfTest: SelfTestConfig{
+					Schedule:    "0 3 * * 0",
+					Concurrency: 1,
+					Timeout:     10 * time.Minute,
+				},
+			},
+			Smartctl: SmartctlConfig{
+				MinInterval:  60 * time.Second,
+				Timeout:      60 * time.Second,
+				SmartctlPath: "smartctl",
+			},
+		},
+		Modules: map[string]Module{},
+	}
+}
+
+// Load reads the configuration file at path on top of Default and validates it.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := Default()
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Validate checks the configuration and fills in the defaults of the modules.
+func (c *Config) Validate() error {
+	if c.Web.ListenAddress == "" {
+		return errors.New("web.listen_address must not be empty")
+	}
+	if !strings.HasPrefix(c.Web.MetricsPath, "/") || c.Web.MetricsPath == "/probe" {
+		return fmt.Errorf("web.metrics_path %q must start with / and differ from /probe", c.Web.MetricsPath)
+	}
+
+	idrac := c.Collectors.IDRAC
+	switch idrac.Source {
+	case "racadm":
+		if idrac.RacadmPath == "" {
+			return errors.New("collectors.idrac.racadm_path must not be empty")
+		}
+	case "redfish":
+		if idrac.Enabled && idrac.Redfish.Endpoint == "" {
+			return errors.New("collectors.idrac.redfish.endpoint is required with the redfish source")
+		}
+	default:
+		return fmt.Errorf("collectors.idrac.source: unknown source %q, expected racadm or redfish", idrac.Source)
+	}
+	if err := validateDurations("collectors.idrac", idrac.MinInterval, idrac.Timeout, idrac.AbsentGracePeriod); err != nil {
+		return err
+	}
+
+	smart := c.Collectors.SMART
+	if smart.NVMePath == "" || smart.SysfsRoot == "" {
+		return errors.New("collectors.smart.nvme_path and collectors.smart.sysfs_root must not be empty")
+	}
+	if err := validateDurations("collectors.smart", smart.MinInterval, smart.Timeout, smart.AbsentGracePeriod); err != nil {
+		return err
+	}
+	if err := smart.SelfTest.validate(); err != nil {
+		return err
+	}
+	for model, revisions := range smart.ApprovedFirmware {
+		if strings.TrimSpace(model) == "" || len(revisions) == 0 {
+			return fmt.Errorf("collectors.smart.approved_firmware: model %q must not be empty and list at least one firmware", model)
+		}
+	}
+
+	smartctl := c.Collectors.Smartctl
+	if smartctl.SmartctlPath == "" {
+		return errors.New("collectors.smartctl.smartctl_path must not be empty")
+	}
+	if err := validateDurations("collectors.smartctl", smartctl.MinInterval, smartctl.Timeout, 0); err != nil {
+		return err
+	}
+
+	for name, module := range c.Modules {
+		switch module.Source {
+		case "":
+			module.Source = "redfish"
+		case "redfish", "racadm":
+		default:
+			return fmt.Errorf("module %q: unknown source %q, expected redfish or racadm", name, module.Source)
+		}
+		if module.Timeout < 0 {
+			return fmt.Errorf("module %q: timeout must not be negative", name)
+		}
+		if len(module.Targets) == 0 {
+			return fmt.Errorf("module %q: targets must list the hosts or networks the module may probe", name)
+		}
+		for _, target := range module.Targets {
+			if !validTarget(target) {
+				return fmt.Errorf("module %q: invalid target %q, expected a host name, an IP address or a CIDR network", name, target)
+			}
+		}
+		c.Modules[name] = module
+	}
+	return nil
+}
+
+// validTarget reports whether target is a CIDR network, an IP address or a
+// host name without port.
+func validTarget(target string) bool {
+	if _, _, err := net.ParseCIDR(target); err == nil {
+		return true
+	}
+	if net.ParseIP(target) != nil {
+		return true
+	}
+	return target != "" && !strings.HasPrefix(target, "-") && !strings.ContainsAny(target, "/: \t")
+}
+
+func (c SelfTestConfig) validate() error {
+	if _, err := smart.ParseSchedule(c.Schedule); err != nil {
+		return fmt.Errorf("collectors.smart.self_test.schedule: %w", err)
+	}
+	if c.Concurrency < 1 {
+		return errors.New("collectors.smart.self_test.concurrency must be at least 1")
+	}
+	if c.Timeout <= 0 {
+		return errors.New("collectors.smart.self_test.timeout must be positive")
+	}
+	return nil
+}
+
+func validateDurations(collector string, minInterval, timeout, absentGracePeriod time.Duration) error {
+	if minInterval < 0 {
+		return fmt.Errorf("%s.min_interval must not be negative", collector)
+	}
+	if timeout <= 0 {
+		return fmt.Errorf("%s.timeout must be positive", collector)
+	}
+	if absentGracePeriod < 0 {
+		return fmt.Errorf("%s.absent_grace_period must not be negative", collector)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Error writing config: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+modules:
+  default:
+    username: root
+    password: calvin
+    insecure_skip_verify: true
+    timeout: 30s
+    targets: [idrac1.example.com, 10.0.0.0/24]
+  legacy:
+    source: racadm
+    username: admin
+    password: secret
+    targets: [192.0.2.10]
+`)
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := Module{
+		Source:             "redfish",
+		Username:           "root",
+		Password:           "calvin",
+		InsecureSkipVerify: true,
+		Timeout:            30 * time.Second,
+		Targets:            []string{"idrac1.example.com", "10.0.0.0/24"},
+	}
+	if module := config.Modules["default"]; !reflect.DeepEqual(module, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, module)
+	}
+	if source := config.Modules["legacy"].Source; source != "racadm" {
+		t.Fatalf("Expected source racadm, got %q", source)
+	}
+}
+
+func TestLoadApprovedFirmware(t *testing.T) {
+	path := writeConfig(t, `
+collectors:
+  smart:
+    approved_firmware:
+      "Dell Express Flash NVMe P4610 1.6TB SFF": [VDV1DP23, VDV1DP25]
+`)
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	revisions := config.Collectors.SMART.ApprovedFirmware["Dell Express Flash NVMe P4610 1.6TB SFF"]
+	if len(revisions) != 2 || revisions[1] != "VDV1DP25" {
+		t.Fatalf("Unexpected approved firmware %v", revisions)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown source":                  "modules:\n  default:\n    source: ipmi\n",
+		"unknown field":                   "modules:\n  default:\n    user: root\n",
+		"invalid yaml":                    "modules: [",
+		"missing redfish endpoint":        "collectors:\n  idrac:\n    source: redfish\n",
+		"negative grace period":           "collectors:\n  smart:\n    absent_grace_period: -1m\n",
+		"empty racadm path":               "collectors:\n  idrac:\n    racadm_path: \"\"\n",
+		"invalid metrics path":            "web:\n  metrics_path: /probe\n",
+		"zero smartctl timeout":           "collectors:\n  smartctl:\n    enabled: true\n    timeout: 0s\n",
+		"invalid self-test schedule":      "collectors:\n  smart:\n    self_test:\n      schedule: \"0 3 * *\"\n",
+		"approved model without firmware": "collectors:\n  smart:\n    approved_firmware:\n      \"Dell Ent NVMe v2 AGN RI U.2 1.92TB\": []\n",
+		"zero self-test concurrency":      "collectors:\n  smart:\n    self_test:\n      concurrency: 0\n",
+		"module without targets":          "modules:\n  default:\n    username: root\n",
+		"invalid module target":           "modules:\n  default:\n    targets: [\"-p\"]\n",
+	}
+
+	for name, content := range tests {
+		if _, err := Load(writeConfig(t, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestModuleAllows(t *testing.T) {
+	module := Module{Targets: []string{"idrac1.example.com", "192.0.2.10", "10.0.0.0/24", "2001:db8::/64"}}
+	for host, expected := range map[string]bool{
+		"idrac1.example.com":  true,
+		"IDRAC1.example.com.": true,
+		"idrac2.example.com":  false,
+		"192.0.2.10":          true,
+		"192.0.2.11":          false,
+		"10.0.0.42":           true,
+		"10.0.1.42":           false,
+		"2001:db8::1":         true,
+	} {
+		if allowed := module.Allows(host); allowed != expected {
+			t.Errorf("%s: expected %v, got %v", host, expected, allowed)
+		}
+	}
+}
+
+func TestLoadEmpty(t *testing.T) {
+	config, err := Load(writeConfig(t, ""))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if config.Web != Default().Web || !reflect.DeepEqual(config.Collectors, Default().Collectors) {
+		t.Fatalf("Expected the default configuration, got %+v", config)
+	}
+}
+
+func TestDefaultMinInterval(t *testing.T) {
+	collectors := Default().Collectors
+	for name, interval := range map[string]time.Duration{
+		"idrac":    collectors.IDRAC.MinInterval,
+		"smart":    collectors.SMART.MinInterval,
+		"smartctl": collectors.Smartctl.MinInterval,
+	} {
+		if interval != time.Minute {
+			t.Errorf("Expected a 1m default min_interval for %s, got %s", name, interval)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package exporter
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BreakerState is the state of a Breaker, exported as its gauge value.
+type BreakerState int
+
+const (
+	// BreakerClosed lets collections run, after the backoff of the last failure.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen skips collections until MaxBackoff has elapsed.
+	BreakerOpen
+	// BreakerHalfOpen lets a single trial collection run after the breaker was open.
+	BreakerHalfOpen
+)
+
+// BackoffConfig configures the retry policy of a Breaker.
+type BackoffConfig struct {
+	// InitialBackoff is the delay after the first failure, doubled on each
+	// further failure up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter randomizes each delay by up to this fraction, e.g. 0.2 for ±20%.
+	Jitter float64
+	// FailureThreshold is the number of consecutive failures opening the breaker.
+	FailureThreshold int
+}
+
+// DefaultBackoff is the retry policy of the collectors.
+var DefaultBackoff = BackoffConfig{
+	InitialBackoff:   10 * time.Second,
+	MaxBackoff:       10 * time.Minute,
+	Jitter:           0.2,
+	FailureThreshold: 5,
+}
+
+// Breaker spaces out the collections of a failing collector with exponential
+// backoff and, after FailureThreshold consecutive failures, opens a circuit
+// breaker so that hanging or missing tools are not run on every scrape.
+type Breaker struct {
+	mu          sync.Mutex
+	config      BackoffConfig
+	now         func() time.Time
+	random      func() float64
+	state       BreakerState
+	failures    int
+	nextAttempt time.Time
+
+	stateGauge    prometheus.Gauge
+	failuresGauge prometheus.Gauge
+	backoffGauge  prometheus.Gauge
+}
+
+// NewBreaker returns a Breaker for collector using config.
+func NewBreaker(collector string, config BackoffConfig) *Breaker {
+	labels := prometheus.Labels{"collector": collector}
+	return &Breaker{
+		config: config,
+		now:    time.Now,
+		random: rand.Float64,
+		stateGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "collector_breaker_state",
+			Help:        "State of the collector circuit breaker (0=Closed, 1=Open, 2=HalfOpen)",
+			ConstLabels: labels,
+		}),
+		failuresGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "collector_consecutive_failures",
+			Help:        "Number of consecutive failed collections",
+			ConstLabels: labels,
+		}),
+		backoffGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "collector_backoff_seconds",
+			Help:        "Delay before the next collection is attempted after a failure",
+			ConstLabels: labels,
+		}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (b *Breaker) Describe(ch chan<- *prometheus.Desc) {
+	b.stateGauge.Describe(ch)
+	b.failuresGauge.Describe(ch)
+	b.backoffGauge.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (b *Breaker) Collect(ch chan<- prometheus.Metric) {
+	b.stateGauge.Collect(ch)
+	b.failuresGauge.Collect(ch)
+	b.backoffGauge.Collect(ch)
+}
+
+// Allow reports whether a collection may run now. An open breaker turns
+// half-open once its backoff has elapsed and allows a single trial.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.now().Before(b.nextAttempt) {
+		return false
+	}
+	if b.state == BreakerOpen {
+		b.setState(BreakerHalfOpen)
+	}
+	return true
+}
+
+// Record reports the result of a collection allowed by Allow.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		b.nextAttempt = time.Time{}
+		b.setState(BreakerClosed)
+		b.failuresGauge.Set(0)
+		b.backoffGauge.Set(0)
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.config.FailureThreshold {
+		b.setState(BreakerOpen)
+	}
+	backoff := b.backoff()
+	b.nextAttempt = b.now().Add(backoff)
+	b.failuresGauge.Set(float64(b.failures))
+	b.backoffGauge.Set(backoff.Seconds())
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// backoff returns the jittered delay after the current failure: exponential
+// while closed and MaxBackoff once open.
+func (b *Breaker) backoff() time.Duration {
+	delay := b.config.MaxBackoff
+	if b.state == BreakerClosed {
+		exponential := float64(b.config.InitialBackoff) * math.Pow(2, float64(b.failures-1))
+		delay = time.Duration(math.Min(exponential, float64(b.config.MaxBackoff)))
+	}
+	jitter := 1 + b.config.Jitter*(2*b.random()-1)
+	return time.Duration(float64(delay) * jitter)
+}
+
+func (b *Breaker) setState(state BreakerState) {
+	b.state = state
+	b.stateGauge.Set(float64(state))
+}
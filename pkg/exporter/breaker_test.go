@@ -0,0 +1,81 @@
+package exporter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestBreaker(now *time.Time) *Breaker {
+	breaker := NewBreaker("test", BackoffConfig{
+		InitialBackoff:   10 * time.Second,
+		MaxBackoff:       time.Minute,
+		Jitter:           0.2,
+		FailureThreshold: 3,
+	})
+	breaker.now = func() time.Time { return *now }
+	// Always draw the largest jitter
+	breaker.random = func() float64 { return 1 }
+	return breaker
+}
+
+func TestBreakerBackoff(t *testing.T) {
+	now := time.Date(2024, 6, 19, 12, 0, 0, 0, time.UTC)
+	breaker := newTestBreaker(&now)
+	failure := errors.New("racadm: exit status 1")
+
+	// 10s, 20s, then the breaker opens with the maximum backoff, each +20%
+	for i, expected := range []time.Duration{12 * time.Second, 24 * time.Second, 72 * time.Second} {
+		if !breaker.Allow() {
+			t.Fatalf("Attempt %d: expected the collection to be allowed", i)
+		}
+		breaker.Record(failure)
+		if backoff := testutil.ToFloat64(breaker.backoffGauge); backoff != expected.Seconds() {
+			t.Fatalf("Attempt %d: expected a %s backoff, got %vs", i, expected, backoff)
+		}
+		now = now.Add(expected - time.Second)
+		if breaker.Allow() {
+			t.Fatalf("Attempt %d: expected the collection to be skipped during the backoff", i)
+		}
+		now = now.Add(time.Second)
+	}
+	if state := testutil.ToFloat64(breaker.stateGauge); state != float64(BreakerOpen) {
+		t.Fatalf("Expected the breaker to be open, got %v", state)
+	}
+	if failures := testutil.ToFloat64(breaker.failuresGauge); failures != 3 {
+		t.Fatalf("Expected 3 consecutive failures, got %v", failures)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	now := time.Date(2024, 6, 19, 12, 0, 0, 0, time.UTC)
+	breaker := newTestBreaker(&now)
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Hour)
+		breaker.Allow()
+		breaker.Record(errors.New("timeout"))
+	}
+
+	// A failed trial reopens the breaker
+	now = now.Add(time.Hour)
+	if !breaker.Allow() || breaker.State() != BreakerHalfOpen {
+		t.Fatalf("Expected a half-open trial, got state %v", breaker.State())
+	}
+	breaker.Record(errors.New("timeout"))
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("Expected the breaker to reopen, got state %v", breaker.State())
+	}
+
+	// A successful trial closes it
+	now = now.Add(time.Hour)
+	breaker.Allow()
+	breaker.Record(nil)
+	if breaker.State() != BreakerClosed || !breaker.Allow() {
+		t.Fatalf("Expected the breaker to be closed, got state %v", breaker.State())
+	}
+	if failures := testutil.ToFloat64(breaker.failuresGauge); failures != 0 {
+		t.Fatalf("Expected the failures to be reset, got %v", failures)
+	}
+}
@@ -0,0 +1,107 @@
+// Package exporter provides the self-monitoring metrics shared by the
+// collectors of the exporter.
+package exporter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os/exec"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "dell_disk_exporter"
+
+// CollectorMetrics is a prometheus.Collector exporting the health of one
+// collector, so Prometheus can tell fresh data from stale data when the
+// underlying tools fail.
+type CollectorMetrics struct {
+	success       prometheus.Gauge
+	duration      prometheus.Gauge
+	lastSuccess   prometheus.Gauge
+	commandErrors *prometheus.CounterVec
+}
+
+// NewCollectorMetrics returns the self-monitoring metrics of collector,
+// e.g. "idrac" or "smart". They are labelled with the collector name so every
+// collector can register its own instance in the same registry.
+func NewCollectorMetrics(collector string) *CollectorMetrics {
+	labels := prometheus.Labels{"collector": collector}
+	return &CollectorMetrics{
+		success: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "collector_success",
+			Help:        "Whether the last collection succeeded",
+			ConstLabels: labels,
+		}),
+		duration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "collector_duration_seconds",
+			Help:        "Duration of the last collection in seconds",
+			ConstLabels: labels,
+		}),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "last_success_timestamp_seconds",
+			Help:        "Unix timestamp of the last successful collection",
+			ConstLabels: labels,
+		}),
+		commandErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "command_errors_total",
+			Help:        "Number of failed commands by reason (timeout, not_found, exit_status, error)",
+			ConstLabels: labels,
+		}, []string{"command", "reason"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *CollectorMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.success.Describe(ch)
+	m.duration.Describe(ch)
+	m.lastSuccess.Describe(ch)
+	m.commandErrors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *CollectorMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.success.Collect(ch)
+	m.duration.Collect(ch)
+	m.lastSuccess.Collect(ch)
+	m.commandErrors.Collect(ch)
+}
+
+// Observe records a collection that started at start and failed if err is not nil.
+func (m *CollectorMetrics) Observe(start time.Time, err error) {
+	now := time.Now()
+	m.duration.Set(now.Sub(start).Seconds())
+	if err != nil {
+		m.success.Set(0)
+		return
+	}
+	m.success.Set(1)
+	m.lastSuccess.Set(float64(now.UnixNano()) / 1e9)
+}
+
+// CommandError counts a failure of command, e.g. "GetRAIDStatus".
+func (m *CollectorMetrics) CommandError(command string, err error) {
+	m.commandErrors.WithLabelValues(command, Reason(err)).Inc()
+}
+
+// Reason classifies err for the reason label of command_errors_total.
+func Reason(err error) string {
+	var netErr net.Error
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, exec.ErrNotFound):
+		return "not_found"
+	case errors.As(err, &exitErr):
+		return "exit_status"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,67 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestReason(t *testing.T) {
+	_, notFound := exec.LookPath("dell-disk-exporter-missing-command")
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{fmt.Errorf("racadm timed out: %w", context.DeadlineExceeded), "timeout"},
+		{notFound, "not_found"},
+		{exitErr, "exit_status"},
+		{errors.New("unexpected status 500"), "error"},
+	}
+
+	for _, test := range tests {
+		if reason := Reason(test.err); reason != test.expected {
+			t.Errorf("Reason(%v) = %q, expected %q", test.err, reason, test.expected)
+		}
+	}
+}
+
+func TestCollectorMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	idrac := NewCollectorMetrics("idrac")
+	smart := NewCollectorMetrics("smart")
+	registry.MustRegister(idrac, smart)
+
+	idrac.Observe(time.Now(), nil)
+	smart.CommandError("GetNVMeDrives", exec.ErrNotFound)
+	smart.Observe(time.Now(), exec.ErrNotFound)
+
+	expected := `
+# HELP dell_disk_exporter_collector_success Whether the last collection succeeded
+# TYPE dell_disk_exporter_collector_success gauge
+dell_disk_exporter_collector_success{collector="idrac"} 1
+dell_disk_exporter_collector_success{collector="smart"} 0
+# HELP dell_disk_exporter_command_errors_total Number of failed commands by reason (timeout, not_found, exit_status, error)
+# TYPE dell_disk_exporter_command_errors_total counter
+dell_disk_exporter_command_errors_total{collector="smart",command="GetNVMeDrives",reason="not_found"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"dell_disk_exporter_collector_success", "dell_disk_exporter_command_errors_total"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+
+	if timestamp := testutil.ToFloat64(idrac.lastSuccess); timestamp == 0 {
+		t.Fatal("Expected the last success timestamp to be set")
+	}
+	if timestamp := testutil.ToFloat64(smart.lastSuccess); timestamp != 0 {
+		t.Fatalf("Expected no last success timestamp, got %v", timestamp)
+	}
+}
@@ -0,0 +1,140 @@
+package idrac
+
+import (
+	"log"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// controllerMetrics holds the gauges exported for RAID controllers and their batteries.
+type controllerMetrics struct {
+	status         *prometheus.GaugeVec
+	info           *prometheus.GaugeVec
+	cacheSizeBytes *prometheus.GaugeVec
+	batteryStatus  *prometheus.GaugeVec
+	batteryState   *prometheus.GaugeVec
+	batteryLearn   *prometheus.GaugeVec
+}
+
+func newControllerMetrics() *controllerMetrics {
+	m := &controllerMetrics{
+		status: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_controller_status",
+				Help: "Status of the RAID controller (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)",
+			},
+			[]string{"controller"},
+		),
+		info: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_controller_info",
+				Help: "Information about the RAID controller, always 1",
+			},
+			[]string{"controller", "name", "firmware_version", "driver_version"},
+		),
+		cacheSizeBytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_controller_cache_size_bytes",
+				Help: "Size of the RAID controller cache memory in bytes",
+			},
+			[]string{"controller"},
+		),
+		batteryStatus: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_battery_status",
+				Help: "Status of the RAID controller battery (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)",
+			},
+			[]string{"battery"},
+		),
+		batteryState: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_battery_state",
+				Help: "State of the RAID controller battery as reported by racadm, 1 for the current state",
+			},
+			[]string{"battery", "state"},
+		),
+		batteryLearn: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_battery_learn_cycle_status",
+				Help: "Learn cycle status of the RAID controller battery as reported by racadm, 1 for the current status",
+			},
+			[]string{"battery", "status"},
+		),
+	}
+
+	return m
+}
+
+func (m *controllerMetrics) vecs() []*prometheus.GaugeVec {
+	return []*prometheus.GaugeVec{
+		m.status,
+		m.info,
+		m.cacheSizeBytes,
+		m.batteryStatus,
+		m.batteryState,
+		m.batteryLearn,
+	}
+}
+
+// GetControllers returns the racadm properties of every storage controller keyed by its FQDD,
+// e.g. "RAID.Integrated.1-1".
+func (r *RacadmSource) GetControllers() (map[string]map[string]string, error) {
+	// Controller FQDDs are the only ones without a parent component.
+	return r.getRacadmObjects("controllers", func(fqdd string) string {
+		if strings.Contains(fqdd, ":") {
+			return ""
+		}
+		return fqdd
+	})
+}
+
+// GetBatteries returns the racadm properties of every controller battery keyed by its FQDD,
+// e.g. "Battery.Integrated.1:RAID.Integrated.1-1".
+func (r *RacadmSource) GetBatteries() (map[string]map[string]string, error) {
+	return r.getRacadmObjects("batteries", fqddWithPrefix("Battery."))
+}
+
+// updateControllerMetrics refreshes the controller and battery metrics.
+func (c *Client) updateControllerMetrics() {
+	controllers, err := c.GetControllers()
+	if err != nil {
+		log.Printf("Error fetching RAID controllers: %v", err)
+	} else {
+		for controller, properties := range controllers {
+			c.controllers.status.WithLabelValues(controller).Set(float64(ParseHealthStatus(properties["Status"])))
+			c.setProperty(c.controllers.cacheSizeBytes, controller, properties, "CacheMemorySize", func(value string) (float64, error) {
+				return ParseSize(value, BinarySizeBase)
+			})
+			c.controllers.info.DeletePartialMatch(prometheus.Labels{"controller": controller})
+			c.controllers.info.WithLabelValues(
+				controller,
+				properties["Name"],
+				properties["FirmwareVersion"],
+				properties["DriverVersion"],
+			).Set(1)
+		}
+	}
+
+	batteries, err := c.GetBatteries()
+	if err != nil {
+		log.Printf("Error fetching RAID batteries: %v", err)
+		return
+	}
+	for battery, properties := range batteries {
+		c.controllers.batteryStatus.WithLabelValues(battery).Set(float64(ParseHealthStatus(properties["Status"])))
+		setCurrentLabel(c.controllers.batteryState, "battery", battery, properties["State"])
+		setCurrentLabel(c.controllers.batteryLearn, "battery", battery, firstProperty(properties, "LearnState", "LearnCycleStatus"))
+	}
+}
+
+// firstProperty returns the first non-empty value among keys, for properties
+// that are named differently across racadm versions.
+func firstProperty(properties map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if value := properties[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package idrac
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const controllersOutput = `
+RAID.Integrated.1-1
+   Status                           = Ok
+   DeviceDescription                = Integrated RAID Controller 1
+   RollupStatus                     = Ok
+   Name                             = PERC H730P Mini
+   PciSlot                          = Not Applicable
+   FirmwareVersion                  = 25.5.9.0001
+   RebuildRate                      = 30
+   BgiRate                          = 30
+   CheckConsistencyRate             = 30
+   ReconstructRate                  = 30
+   PatrolReadRate                   = 30
+   PatrolReadMode                   = Automatic
+   CacheMemorySize                  = 2048 MB
+   DriverVersion                    = 07.713.01.00-rc1
+AHCI.Embedded.1-1
+   Status                           = Ok
+   DeviceDescription                = Embedded AHCI 1
+   Name                             = Lewisburg SATA Controller
+   FirmwareVersion                  = Not Applicable
+   DriverVersion                    = 3.0
+`
+
+const batteriesOutput = `
+Battery.Integrated.1:RAID.Integrated.1-1
+   Name                             = Battery
+   DeviceDescription                = Battery on Integrated raid Controller 1
+   Status                           = Degraded
+   State                            = Failed
+   LearnState                       = Timed Out
+`
+
+func TestGetControllers(t *testing.T) {
+	mockExecutor := &CommandMockExecutor{
+		Outputs: map[string]string{"racadm raid get controllers -o": controllersOutput},
+	}
+
+	registry := prometheus.NewRegistry()
+	client := NewClient(mockExecutor, registry, 5*time.Minute)
+	controllers, err := client.GetControllers()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(controllers) != 2 {
+		t.Fatalf("Expected 2 controllers, got %d", len(controllers))
+	}
+	if controllers["RAID.Integrated.1-1"]["FirmwareVersion"] != "25.5.9.0001" {
+		t.Fatalf("Expected FirmwareVersion to be 25.5.9.0001, got %s", controllers["RAID.Integrated.1-1"]["FirmwareVersion"])
+	}
+}
+
+func TestUpdateMetricsControllers(t *testing.T) {
+	mockExecutor := &CommandMockExecutor{
+		Outputs: map[string]string{
+			"racadm raid get vdisks -o -p " + vdiskProperties:                    vdisksOutput,
+			"racadm raid get pdisks -o":                                          "",
+			"racadm raid get pdisks --refkey Disk.Virtual.0:RAID.Integrated.1-1": "",
+			"racadm raid get controllers -o":                                     controllersOutput,
+			"racadm raid get batteries -o":                                       batteriesOutput,
+		},
+	}
+
+	registry := prometheus.NewRegistry()
+	NewClient(mockExecutor, registry, 5*time.Minute)
+
+	expected := `
+# HELP raid_battery_learn_cycle_status Learn cycle status of the RAID controller battery as reported by racadm, 1 for the current status
+# TYPE raid_battery_learn_cycle_status gauge
+raid_battery_learn_cycle_status{battery="Battery.Integrated.1:RAID.Integrated.1-1",status="Timed Out"} 1
+# HELP raid_battery_state State of the RAID controller battery as reported by racadm, 1 for the current state
+# TYPE raid_battery_state gauge
+raid_battery_state{battery="Battery.Integrated.1:RAID.Integrated.1-1",state="Failed"} 1
+# HELP raid_battery_status Status of the RAID controller battery (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)
+# TYPE raid_battery_status gauge
+raid_battery_status{battery="Battery.Integrated.1:RAID.Integrated.1-1"} 3
+# HELP raid_controller_cache_size_bytes Size of the RAID controller cache memory in bytes
+# TYPE raid_controller_cache_size_bytes gauge
+raid_controller_cache_size_bytes{controller="RAID.Integrated.1-1"} 2.147483648e+09
+# HELP raid_controller_info Information about the RAID controller, always 1
+# TYPE raid_controller_info gauge
+raid_controller_info{controller="AHCI.Embedded.1-1",driver_version="3.0",firmware_version="Not Applicable",name="Lewisburg SATA Controller"} 1
+raid_controller_info{controller="RAID.Integrated.1-1",driver_version="07.713.01.00-rc1",firmware_version="25.5.9.0001",name="PERC H730P Mini"} 1
+# HELP raid_controller_status Status of the RAID controller (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)
+# TYPE raid_controller_status gauge
+raid_controller_status{controller="AHCI.Embedded.1-1"} 1
+raid_controller_status{controller="RAID.Integrated.1-1"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"raid_battery_learn_cycle_status", "raid_battery_state", "raid_battery_status",
+		"raid_controller_cache_size_bytes", "raid_controller_info", "raid_controller_status"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
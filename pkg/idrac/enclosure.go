@@ -0,0 +1,140 @@
+package idrac
+
+import (
+	"log"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// enclosureMetrics holds the gauges exported for enclosures, backplanes and their components.
+type enclosureMetrics struct {
+	status      *prometheus.GaugeVec
+	slots       *prometheus.GaugeVec
+	info        *prometheus.GaugeVec
+	emmStatus   *prometheus.GaugeVec
+	temperature *prometheus.GaugeVec
+}
+
+func newEnclosureMetrics() *enclosureMetrics {
+	m := &enclosureMetrics{
+		status: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_enclosure_status",
+				Help: "Status of the enclosure or backplane (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)",
+			},
+			[]string{"enclosure"},
+		),
+		slots: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_enclosure_slots",
+				Help: "Number of drive slots in the enclosure or backplane",
+			},
+			[]string{"enclosure"},
+		),
+		info: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_enclosure_info",
+				Help: "Information about the enclosure or backplane, always 1",
+			},
+			[]string{"enclosure", "controller", "name", "connector", "firmware_version"},
+		),
+		emmStatus: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_enclosure_emm_status",
+				Help: "Status of the enclosure management module (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)",
+			},
+			[]string{"emm", "enclosure"},
+		),
+		temperature: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_enclosure_temperature_celsius",
+				Help: "Temperature reported by the enclosure temperature probe in degrees Celsius",
+			},
+			[]string{"probe", "enclosure"},
+		),
+	}
+
+	return m
+}
+
+func (m *enclosureMetrics) vecs() []*prometheus.GaugeVec {
+	return []*prometheus.GaugeVec{
+		m.status,
+		m.slots,
+		m.info,
+		m.emmStatus,
+		m.temperature,
+	}
+}
+
+// GetEnclosures returns the racadm properties of every enclosure and backplane keyed by its FQDD,
+// e.g. "Enclosure.Internal.0-1:RAID.Integrated.1-1".
+func (r *RacadmSource) GetEnclosures() (map[string]map[string]string, error) {
+	return r.getRacadmObjects("enclosures", fqddWithPrefix("Enclosure."))
+}
+
+// GetEMMs returns the racadm properties of every enclosure management module keyed by its FQDD.
+// Internal backplanes have no EMMs, in which case racadm returns an error.
+func (r *RacadmSource) GetEMMs() (map[string]map[string]string, error) {
+	return r.getRacadmObjects("emms", fqddWithPrefix("EMM."))
+}
+
+// GetTemperatureProbes returns the racadm properties of every enclosure temperature probe keyed by its FQDD.
+// Internal backplanes have no probes, in which case racadm returns an error.
+func (r *RacadmSource) GetTemperatureProbes() (map[string]map[string]string, error) {
+	return r.getRacadmObjects("tempprobes", fqddWithPrefix("Temp"))
+}
+
+// updateEnclosureMetrics refreshes the enclosure, EMM and temperature probe metrics.
+func (c *Client) updateEnclosureMetrics() {
+	enclosures, err := c.GetEnclosures()
+	if err != nil {
+		log.Printf("Error fetching enclosures: %v", err)
+		return
+	}
+	for enclosure, properties := range enclosures {
+		c.enclosures.status.WithLabelValues(enclosure).Set(float64(ParseHealthStatus(properties["Status"])))
+		c.setProperty(c.enclosures.slots, enclosure, properties, "SlotCount", parseToFloat)
+		c.enclosures.info.DeletePartialMatch(prometheus.Labels{"enclosure": enclosure})
+		c.enclosures.info.WithLabelValues(
+			enclosure,
+			controllerFromFQDD(enclosure),
+			properties["Name"],
+			properties["Connector"],
+			properties["FirmwareVersion"],
+		).Set(1)
+	}
+
+	// EMMs and temperature probes only exist on external enclosures.
+	if emms, err := c.GetEMMs(); err == nil {
+		for emm, properties := range emms {
+			c.enclosures.emmStatus.WithLabelValues(emm, parentFromFQDD(emm)).Set(float64(ParseHealthStatus(properties["Status"])))
+		}
+	}
+	if probes, err := c.GetTemperatureProbes(); err == nil {
+		for probe, properties := range probes {
+			reading := firstProperty(properties, "CurrentReading", "Reading")
+			if reading == "" || notApplicable(reading) {
+				continue
+			}
+			celsius, err := parseToFloat(reading)
+			if err != nil {
+				log.Printf("Error parsing temperature for %s: %v", probe, err)
+				c.parseErrors.WithLabelValues(probe, "CurrentReading").Inc()
+				continue
+			}
+			c.enclosures.temperature.WithLabelValues(probe, parentFromFQDD(probe)).Set(celsius)
+		}
+	}
+}
+
+// parentFromFQDD strips the first component of an FQDD, e.g. the enclosure
+// "Enclosure.External.0-0:RAID.Slot.3-1" for "EMM.Slot.0:Enclosure.External.0-0:RAID.Slot.3-1".
+func parentFromFQDD(fqdd string) string {
+	parts := strings.SplitN(fqdd, ":", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return parts[1]
+}
@@ -0,0 +1,120 @@
+package idrac
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const enclosuresOutput = `
+Enclosure.Internal.0-1:RAID.Integrated.1-1
+   Status                           = Ok
+   DeviceDescription                = Backplane 1 on Connector 0 of Integrated RAID Controller 1
+   RollupStatus                     = Ok
+   Name                             = BP14G+ 0:1
+   BayId                            = 1
+   FirmwareVersion                  = 4.26
+   SasAddress                       = 0x500056B31234ABFD
+   SlotCount                        = 8
+   Connector                        = 0
+Enclosure.External.0-0:RAID.Slot.3-1
+   Status                           = Non-Critical
+   DeviceDescription                = Enclosure 0 on Connector 0 of RAID Controller in Slot 3
+   Name                             = MD1400 0:0
+   FirmwareVersion                  = 1.05
+   SlotCount                        = 12
+   Connector                        = 0
+`
+
+const emmsOutput = `
+EMM.Slot.0:Enclosure.External.0-0:RAID.Slot.3-1
+   Status                           = Ok
+   Name                             = EMM 0
+   State                            = Ready
+EMM.Slot.1:Enclosure.External.0-0:RAID.Slot.3-1
+   Status                           = Failed
+   Name                             = EMM 1
+   State                            = Failed
+`
+
+const tempProbesOutput = `
+TempSensor.Slot.0:Enclosure.External.0-0:RAID.Slot.3-1
+   Status                           = Ok
+   Name                             = Temperature Probe 0
+   CurrentReading                   = 27 C
+TempSensor.Slot.1:Enclosure.External.0-0:RAID.Slot.3-1
+   Status                           = Ok
+   Name                             = Temperature Probe 1
+   CurrentReading                   = Not Applicable
+`
+
+func TestUpdateEnclosureMetrics(t *testing.T) {
+	mockExecutor := &CommandMockExecutor{
+		Outputs: map[string]string{
+			"racadm raid get vdisks -o -p " + vdiskProperties: "",
+			"racadm raid get pdisks -o":                       "",
+			"racadm raid get controllers -o":                  "",
+			"racadm raid get batteries -o":                    "",
+			"racadm raid get enclosures -o":                   enclosuresOutput,
+			"racadm raid get emms -o":                         emmsOutput,
+			"racadm raid get tempprobes -o":                   tempProbesOutput,
+		},
+	}
+
+	registry := prometheus.NewRegistry()
+	NewClient(mockExecutor, registry, 5*time.Minute)
+
+	expected := `
+# HELP raid_enclosure_emm_status Status of the enclosure management module (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)
+# TYPE raid_enclosure_emm_status gauge
+raid_enclosure_emm_status{emm="EMM.Slot.0:Enclosure.External.0-0:RAID.Slot.3-1",enclosure="Enclosure.External.0-0:RAID.Slot.3-1"} 1
+raid_enclosure_emm_status{emm="EMM.Slot.1:Enclosure.External.0-0:RAID.Slot.3-1",enclosure="Enclosure.External.0-0:RAID.Slot.3-1"} 5
+# HELP raid_enclosure_info Information about the enclosure or backplane, always 1
+# TYPE raid_enclosure_info gauge
+raid_enclosure_info{connector="0",controller="RAID.Integrated.1-1",enclosure="Enclosure.Internal.0-1:RAID.Integrated.1-1",firmware_version="4.26",name="BP14G+ 0:1"} 1
+raid_enclosure_info{connector="0",controller="RAID.Slot.3-1",enclosure="Enclosure.External.0-0:RAID.Slot.3-1",firmware_version="1.05",name="MD1400 0:0"} 1
+# HELP raid_enclosure_slots Number of drive slots in the enclosure or backplane
+# TYPE raid_enclosure_slots gauge
+raid_enclosure_slots{enclosure="Enclosure.External.0-0:RAID.Slot.3-1"} 12
+raid_enclosure_slots{enclosure="Enclosure.Internal.0-1:RAID.Integrated.1-1"} 8
+# HELP raid_enclosure_status Status of the enclosure or backplane (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)
+# TYPE raid_enclosure_status gauge
+raid_enclosure_status{enclosure="Enclosure.External.0-0:RAID.Slot.3-1"} 3
+raid_enclosure_status{enclosure="Enclosure.Internal.0-1:RAID.Integrated.1-1"} 1
+# HELP raid_enclosure_temperature_celsius Temperature reported by the enclosure temperature probe in degrees Celsius
+# TYPE raid_enclosure_temperature_celsius gauge
+raid_enclosure_temperature_celsius{enclosure="Enclosure.External.0-0:RAID.Slot.3-1",probe="TempSensor.Slot.0:Enclosure.External.0-0:RAID.Slot.3-1"} 27
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"raid_enclosure_emm_status", "raid_enclosure_info", "raid_enclosure_slots",
+		"raid_enclosure_status", "raid_enclosure_temperature_celsius"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestUpdateEnclosureMetricsInternalOnly(t *testing.T) {
+	mockExecutor := &CommandMockExecutor{
+		Outputs: map[string]string{
+			"racadm raid get enclosures -o": enclosuresOutput,
+		},
+		Errors: map[string]error{
+			"racadm raid get emms -o":       errors.New("ERROR: STOR0104 : Unable to find EMMs"),
+			"racadm raid get tempprobes -o": errors.New("ERROR: STOR0104 : Unable to find temperature probes"),
+		},
+	}
+
+	registry := prometheus.NewRegistry()
+	client := NewClient(mockExecutor, registry, 5*time.Minute)
+	client.updateEnclosureMetrics()
+
+	if count := testutil.CollectAndCount(client.enclosures.status); count != 2 {
+		t.Fatalf("Expected 2 enclosure status series, got %d", count)
+	}
+	if count := testutil.CollectAndCount(client.enclosures.emmStatus); count != 0 {
+		t.Fatalf("Expected no EMM series, got %d", count)
+	}
+}
@@ -0,0 +1,77 @@
+package idrac
+
+import "strings"
+
+// HealthStatus is the normalized health of a storage object (virtual disk,
+// physical disk, ...) as reported by the racadm Status property. The numeric
+// value doubles as a severity, so Ok is always 1 and anything above it is
+// progressively worse.
+type HealthStatus int
+
+const (
+	// HealthStatusUnknown is used when racadm reports Unknown or a value we do not recognise.
+	HealthStatusUnknown HealthStatus = iota
+	// HealthStatusOk means the object is healthy.
+	HealthStatusOk
+	// HealthStatusRebuilding means a member disk is being rebuilt.
+	HealthStatusRebuilding
+	// HealthStatusDegraded means the object lost redundancy or reports a non-critical fault.
+	HealthStatusDegraded
+	// HealthStatusOffline means the object is not available to the host.
+	HealthStatusOffline
+	// HealthStatusFailed means the object has failed.
+	HealthStatusFailed
+)
+
+// healthStatuses lists every status in severity order, used for one-hot series.
+var healthStatuses = []HealthStatus{
+	HealthStatusUnknown,
+	HealthStatusOk,
+	HealthStatusRebuilding,
+	HealthStatusDegraded,
+	HealthStatusOffline,
+	HealthStatusFailed,
+}
+
+// racadmStatuses maps lower-cased racadm Status strings to a HealthStatus.
+var racadmStatuses = map[string]HealthStatus{
+	"ok":           HealthStatusOk,
+	"online":       HealthStatusOk,
+	"ready":        HealthStatusOk,
+	"rebuilding":   HealthStatusRebuilding,
+	"rebuild":      HealthStatusRebuilding,
+	"degraded":     HealthStatusDegraded,
+	"non-critical": HealthStatusDegraded,
+	"noncritical":  HealthStatusDegraded,
+	"offline":      HealthStatusOffline,
+	"failed":       HealthStatusFailed,
+	"critical":     HealthStatusFailed,
+	"unknown":      HealthStatusUnknown,
+}
+
+// ParseHealthStatus converts a racadm Status value into a HealthStatus.
+// Unrecognised values map to HealthStatusUnknown.
+func ParseHealthStatus(value string) HealthStatus {
+	if status, ok := racadmStatuses[strings.ToLower(strings.TrimSpace(value))]; ok {
+		return status
+	}
+	return HealthStatusUnknown
+}
+
+// String returns the label value used for the status in metrics.
+func (s HealthStatus) String() string {
+	switch s {
+	case HealthStatusOk:
+		return "Ok"
+	case HealthStatusRebuilding:
+		return "Rebuilding"
+	case HealthStatusDegraded:
+		return "Degraded"
+	case HealthStatusOffline:
+		return "Offline"
+	case HealthStatusFailed:
+		return "Failed"
+	default:
+		return "Unknown"
+	}
+}
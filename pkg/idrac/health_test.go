@@ -0,0 +1,90 @@
+package idrac
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseHealthStatus(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected HealthStatus
+	}{
+		{"Ok", HealthStatusOk},
+		{"OK", HealthStatusOk},
+		{"Online", HealthStatusOk},
+		{"Ready", HealthStatusOk},
+		{"Rebuilding", HealthStatusRebuilding},
+		{"Rebuild", HealthStatusRebuilding},
+		{"Degraded", HealthStatusDegraded},
+		{"Non-Critical", HealthStatusDegraded},
+		{"NonCritical", HealthStatusDegraded},
+		{"Offline", HealthStatusOffline},
+		{"Failed", HealthStatusFailed},
+		{"Critical", HealthStatusFailed},
+		{"Unknown", HealthStatusUnknown},
+		{"  Degraded  ", HealthStatusDegraded},
+		{"", HealthStatusUnknown},
+		{"Something New", HealthStatusUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := ParseHealthStatus(tt.input); got != tt.expected {
+			t.Errorf("ParseHealthStatus(%q) = %v, expected %v", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestUpdateMetricsVDiskStatus(t *testing.T) {
+	mockExecutor := &MockCommandExecutor{
+		MockOutput: `
+Disk.Virtual.1:RAID.Integrated.1-1
+   Layout                           = Raid-10
+   Status                           = Degraded
+   RemainingRedundancy              = 0
+   Size                             = 1787.50 GB
+Disk.Virtual.0:RAID.Integrated.1-1
+   Layout                           = Raid-1
+   Status                           = Failed
+   RemainingRedundancy              = 0
+   Size                             = 372.00 GB
+`,
+	}
+
+	registry := prometheus.NewRegistry()
+	NewClient(mockExecutor, registry, 5*time.Minute)
+
+	expectedStatus := `
+# HELP raid_status Status of the RAID virtual disk (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)
+# TYPE raid_status gauge
+raid_status{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 3
+raid_status{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 5
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedStatus), "raid_status"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+
+	expectedHealthStatus := `
+# HELP raid_vdisk_status Status of the RAID virtual disk, 1 for the current status and 0 otherwise
+# TYPE raid_vdisk_status gauge
+raid_vdisk_status{status="Degraded",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 0
+raid_vdisk_status{status="Failed",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+raid_vdisk_status{status="Offline",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 0
+raid_vdisk_status{status="Ok",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 0
+raid_vdisk_status{status="Rebuilding",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 0
+raid_vdisk_status{status="Unknown",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 0
+raid_vdisk_status{status="Degraded",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1
+raid_vdisk_status{status="Failed",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 0
+raid_vdisk_status{status="Offline",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 0
+raid_vdisk_status{status="Ok",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 0
+raid_vdisk_status{status="Rebuilding",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 0
+raid_vdisk_status{status="Unknown",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedHealthStatus), "raid_vdisk_status"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
@@ -2,12 +2,15 @@ package idrac
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/angelhvargas/dell-disk-exporter/pkg/exporter"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -17,117 +20,401 @@ type CommandExecutor interface {
 }
 
 // DefaultCommandExecutor implements CommandExecutor
-type DefaultCommandExecutor struct{}
+type DefaultCommandExecutor struct {
+	// Timeout bounds each command, 60 seconds if zero.
+	Timeout time.Duration
+	// Paths maps command names such as "racadm" to the binary to run.
+	Paths map[string]string
+}
 
 func (e *DefaultCommandExecutor) ExecuteCommand(name string, args ...string) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	timeout := e.Timeout
+	if timeout == 0 {
+		timeout = 60 * time.Second
+	}
+	path := name
+	if p, ok := e.Paths[name]; ok {
+		path = p
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, name, args...)
-	return cmd.CombinedOutput()
+	cmd := exec.CommandContext(ctx, path, args...)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return output, fmt.Errorf("%s timed out: %w", name, ctx.Err())
+	}
+	return output, err
 }
 
+// Source provides the storage inventory turned into metrics by the Client.
+// Objects are returned as racadm-style property maps keyed by FQDD, so every
+// implementation feeds the same collectors.
+type Source interface {
+	GetRAIDStatus() (map[string]map[string]string, error)
+	GetVDiskMembers(vdiskFQDD string) ([]string, error)
+	GetPhysicalDisks() (map[string]map[string]string, error)
+	GetControllers() (map[string]map[string]string, error)
+	GetBatteries() (map[string]map[string]string, error)
+	GetEnclosures() (map[string]map[string]string, error)
+	GetEMMs() (map[string]map[string]string, error)
+	GetTemperatureProbes() (map[string]map[string]string, error)
+}
+
+// Refresher is implemented by Sources reading their whole inventory at once.
+// Refresh is called at the start of every update, and the Get methods then
+// serve the inventory it read instead of querying the iDRAC again.
+type Refresher interface {
+	Refresh() error
+}
+
+// Client is a prometheus.Collector exporting the storage inventory read from
+// its Source. Data is collected on scrape.
 type Client struct {
-	executor       CommandExecutor
+	Source
+	// MinInterval is the minimum time between two reads of the Source. Scrapes
+	// within MinInterval of the previous read are served from its results.
+	MinInterval time.Duration
+
+	mu             sync.Mutex
+	lastUpdate     time.Time
 	registry       *prometheus.Registry
 	raidStatus     *prometheus.GaugeVec
+	vdiskStatus    *prometheus.GaugeVec
 	raidRedundancy *prometheus.GaugeVec
-	raidSize       *prometheus.GaugeVec
-	raidLayout     *prometheus.GaugeVec
+	vdiskSizeBytes *prometheus.GaugeVec
+	parseErrors    *prometheus.CounterVec
+	vdiskInfo      *prometheus.GaugeVec
+	vdiskLevel     *prometheus.GaugeVec
+	pdisks         *pdiskMetrics
+	controllers    *controllerMetrics
+	enclosures     *enclosureMetrics
+	progress       *progressMetrics
+	exporter       *exporter.CollectorMetrics
+	breaker        *exporter.Breaker
+	vdiskPresent   *prometheus.GaugeVec
+	absentVDisks   map[string]time.Time
+	absentDuration time.Duration
 }
 
-func NewClient(executor CommandExecutor, registry *prometheus.Registry) *Client {
+// NewClient creates a Client reading the storage inventory with racadm through
+// executor. See NewClientWithSource.
+func NewClient(executor CommandExecutor, registry *prometheus.Registry, absentDuration time.Duration) *Client {
+	return NewClientWithSource(NewRacadmSource(executor), registry, absentDuration)
+}
+
+// NewClientWithSource creates a Client and registers it in registry.
+// Series of a virtual disk that disappears are kept with raid_vdisk_present
+// set to 0 for absentDuration and then removed.
+func NewClientWithSource(source Source, registry *prometheus.Registry, absentDuration time.Duration) *Client {
 	raidStatus := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "raid_status",
-			Help: "Status of the RAID controller",
+			Help: "Status of the RAID virtual disk (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)",
+		},
+		[]string{"vdisk", "vdisk_fqdd"},
+	)
+	vdiskStatus := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "raid_vdisk_status",
+			Help: "Status of the RAID virtual disk, 1 for the current status and 0 otherwise",
 		},
-		[]string{"vdisk"},
+		[]string{"vdisk", "vdisk_fqdd", "status"},
 	)
 	raidRedundancy := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "raid_redundancy",
 			Help: "Remaining redundancy of the RAID controller",
 		},
-		[]string{"vdisk"},
+		[]string{"vdisk", "vdisk_fqdd"},
 	)
-	raidSize := prometheus.NewGaugeVec(
+	vdiskSizeBytes := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: "raid_size",
-			Help: "Size of the RAID controller",
+			Name: "raid_vdisk_size_bytes",
+			Help: "Size of the RAID virtual disk in bytes",
 		},
-		[]string{"vdisk"},
+		[]string{"vdisk", "vdisk_fqdd"},
 	)
-	raidLayout := prometheus.NewGaugeVec(
+	parseErrors := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "raid_parse_errors_total",
+			Help: "Number of racadm property values that could not be parsed",
+		},
+		[]string{"object", "property"},
+	)
+	vdiskInfo := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
-			Name: "raid_layout",
-			Help: "Layout of the RAID controller",
+			Name: "raid_vdisk_info",
+			Help: "Information about the RAID virtual disk, always 1",
 		},
-		[]string{"vdisk"},
+		[]string{"vdisk", "vdisk_fqdd", "controller", "layout", "name", "stripe_size", "read_policy", "write_policy"},
+	)
+	vdiskLevel := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "raid_vdisk_level",
+			Help: "RAID level of the virtual disk derived from its layout",
+		},
+		[]string{"vdisk", "vdisk_fqdd"},
+	)
+	vdiskPresent := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "raid_vdisk_present",
+			Help: "Presence of the RAID virtual disk, 0 once it is no longer reported by racadm",
+		},
+		[]string{"vdisk", "vdisk_fqdd"},
 	)
 
-	registry.MustRegister(raidStatus)
-	registry.MustRegister(raidRedundancy)
-	registry.MustRegister(raidSize)
-	registry.MustRegister(raidLayout)
-
-	return &Client{
-		executor:       executor,
+	c := &Client{
+		Source:         source,
 		registry:       registry,
 		raidStatus:     raidStatus,
+		vdiskStatus:    vdiskStatus,
 		raidRedundancy: raidRedundancy,
-		raidSize:       raidSize,
-		raidLayout:     raidLayout,
+		vdiskSizeBytes: vdiskSizeBytes,
+		parseErrors:    parseErrors,
+		vdiskInfo:      vdiskInfo,
+		vdiskLevel:     vdiskLevel,
+		pdisks:         newPDiskMetrics(),
+		controllers:    newControllerMetrics(),
+		enclosures:     newEnclosureMetrics(),
+		progress:       newProgressMetrics(),
+		exporter:       exporter.NewCollectorMetrics("idrac"),
+		breaker:        exporter.NewBreaker("idrac", exporter.DefaultBackoff),
+		vdiskPresent:   vdiskPresent,
+		absentVDisks:   make(map[string]time.Time),
+		absentDuration: absentDuration,
 	}
+	registry.MustRegister(c)
+	return c
 }
 
-func (c *Client) GetRAIDStatus() (map[string]map[string]string, error) {
-	log.Println("Executing racadm command to get RAID status...")
-	output, err := c.executor.ExecuteCommand("racadm", "raid", "get", "vdisks", "-o", "-p", "layout,status,RemainingRedundancy,Size")
-	if err != nil {
-		log.Printf("Error executing racadm command: %v", err)
-		return nil, err
-	}
-
-	lines := strings.Split(string(output), "\n")
-	log.Println("Parsing racadm command output...")
-	raidStatuses := make(map[string]map[string]string)
-	var currentVdisk string
-
-	for _, line := range lines {
-		if strings.HasPrefix(line, "Disk.Virtual") {
-			currentVdisk = strings.TrimSpace(strings.Split(line, ":")[1])
-			raidStatuses[currentVdisk] = make(map[string]string)
-		} else if currentVdisk != "" && strings.Contains(line, "=") {
-			parts := strings.SplitN(line, "=", 2)
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			raidStatuses[currentVdisk][key] = value
-		}
+// collectors returns every metric exported by the Client.
+func (c *Client) collectors() []prometheus.Collector {
+	collectors := []prometheus.Collector{
+		c.raidStatus,
+		c.vdiskStatus,
+		c.raidRedundancy,
+		c.vdiskSizeBytes,
+		c.parseErrors,
+		c.vdiskInfo,
+		c.vdiskLevel,
+		c.vdiskPresent,
+		c.exporter,
+		c.breaker,
+	}
+	for _, vec := range c.objectVecs() {
+		collectors = append(collectors, vec)
 	}
+	return collectors
+}
 
-	log.Println("Finished parsing racadm command output.")
-	return raidStatuses, nil
+// objectVecs returns the vectors rebuilt from scratch on every successful
+// update, so objects that are no longer reported disappear. Virtual disk
+// series are instead kept for absentDuration, see Update.
+func (c *Client) objectVecs() []*prometheus.GaugeVec {
+	var vecs []*prometheus.GaugeVec
+	vecs = append(vecs, c.pdisks.vecs()...)
+	vecs = append(vecs, c.controllers.vecs()...)
+	vecs = append(vecs, c.enclosures.vecs()...)
+	vecs = append(vecs, c.progress.vecs()...)
+	return vecs
 }
 
-func (c *Client) UpdateMetrics() {
-	for {
-		statuses, err := c.GetRAIDStatus()
+// Describe implements prometheus.Collector.
+func (c *Client) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector. It reads the Source unless the
+// previous read is younger than MinInterval or the breaker is backing off
+// after failures, in which case the previous results are served.
+func (c *Client) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if (c.lastUpdate.IsZero() || time.Since(c.lastUpdate) >= c.MinInterval) && c.breaker.Allow() {
+		err := c.update()
 		if err != nil {
 			log.Printf("Error fetching RAID status: %v", err)
+		}
+		c.breaker.Record(err)
+	}
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+// Update reads the Source once. It returns an error when the virtual disks
+// cannot be listed, leaving every series of the previous read in place;
+// failures of the other objects are only logged.
+func (c *Client) Update() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.update()
+}
+
+func (c *Client) update() (err error) {
+	start := time.Now()
+	c.lastUpdate = start
+	defer func() { c.exporter.Observe(start, err) }()
+
+	if refresher, ok := c.Source.(Refresher); ok {
+		if err := refresher.Refresh(); err != nil {
+			c.exporter.CommandError("Refresh", err)
+			return err
+		}
+	}
+	statuses, err := c.GetRAIDStatus()
+	if err != nil {
+		c.exporter.CommandError("GetRAIDStatus", err)
+		return err
+	}
+	// Only rebuild the other objects once the iDRAC answered, so a failed
+	// read keeps every series of the previous one, like the virtual disks.
+	for _, vec := range c.objectVecs() {
+		vec.Reset()
+	}
+	for vdisk, metrics := range statuses {
+		log.Printf("RAID Status for %s: %v", vdisk, metrics)
+		c.setStatus(vdisk, ParseHealthStatus(metrics["Status"]))
+		c.setProperty(curryVDisk(c.raidRedundancy, vdisk), vdisk, metrics, "RemainingRedundancy", parseToFloat)
+		c.setProperty(curryVDisk(c.vdiskSizeBytes, vdisk), vdisk, metrics, "Size", func(value string) (float64, error) {
+			return ParseSize(value, BinarySizeBase)
+		})
+		c.setInfo(vdisk, metrics)
+		c.setVDiskOperation(vdisk, metrics)
+		c.vdiskPresent.WithLabelValues(vdiskLabel(vdisk), vdisk).Set(1)
+	}
+
+	// absentVDisks holds the last time each virtual disk was reported.
+	for vdisk, timestamp := range c.absentVDisks {
+		if _, found := statuses[vdisk]; found {
 			continue
 		}
-		for vdisk, metrics := range statuses {
-			log.Printf("RAID Status for %s: %v", vdisk, metrics)
-			c.raidStatus.WithLabelValues(vdisk).Set(float64(1)) // Assuming Status is Ok
-			c.raidRedundancy.WithLabelValues(vdisk).Set(parseToFloat(metrics["RemainingRedundancy"]))
-			c.raidSize.WithLabelValues(vdisk).Set(parseToFloat(metrics["Size"]))
-			c.raidLayout.WithLabelValues(vdisk).Set(float64(1)) // Assuming Layout is set
+		if time.Since(timestamp) > c.absentDuration {
+			log.Printf("Virtual disk %s absent for more than %s, removing its metrics", vdisk, c.absentDuration)
+			c.deleteVDisk(vdisk)
+			delete(c.absentVDisks, vdisk)
+		} else {
+			c.vdiskPresent.WithLabelValues(vdiskLabel(vdisk), vdisk).Set(0)
 		}
-		time.Sleep(30 * time.Second) // Adjust the interval as needed
+	}
+	for vdisk := range statuses {
+		c.absentVDisks[vdisk] = time.Now()
+	}
+
+	c.updatePhysicalDiskMetrics(statuses)
+	c.updateControllerMetrics()
+	c.updateEnclosureMetrics()
+	return nil
+}
+
+// deleteVDisk removes every series exported for vdisk.
+func (c *Client) deleteVDisk(vdisk string) {
+	labels := prometheus.Labels{"vdisk_fqdd": vdisk}
+	c.raidStatus.DeletePartialMatch(labels)
+	c.vdiskStatus.DeletePartialMatch(labels)
+	c.raidRedundancy.DeletePartialMatch(labels)
+	c.vdiskSizeBytes.DeletePartialMatch(labels)
+	c.vdiskInfo.DeletePartialMatch(labels)
+	c.vdiskLevel.DeletePartialMatch(labels)
+	c.vdiskPresent.DeletePartialMatch(labels)
+	c.progress.vdiskProgress.DeletePartialMatch(labels)
+	c.progress.vdiskETA.DeletePartialMatch(labels)
+	c.progress.tracker.forget("vdisk/" + vdisk + "/")
+	c.pdisks.vdisk.DeletePartialMatch(labels)
+}
+
+func (c *Client) setStatus(vdisk string, status HealthStatus) {
+	c.raidStatus.WithLabelValues(vdiskLabel(vdisk), vdisk).Set(float64(status))
+	for _, s := range healthStatuses {
+		value := 0.0
+		if s == status {
+			value = 1
+		}
+		c.vdiskStatus.WithLabelValues(vdiskLabel(vdisk), vdisk, s.String()).Set(value)
+	}
+}
+
+func (c *Client) setInfo(vdisk string, metrics map[string]string) {
+	// Policies can change at runtime (e.g. write-back falling back to write-through),
+	// so drop the previous info series before exporting the current one.
+	c.vdiskInfo.DeletePartialMatch(prometheus.Labels{"vdisk_fqdd": vdisk})
+	c.vdiskInfo.WithLabelValues(
+		vdiskLabel(vdisk),
+		vdisk,
+		controllerFromFQDD(metrics["FQDD"]),
+		metrics["Layout"],
+		metrics["Name"],
+		metrics["StripeSize"],
+		metrics["ReadPolicy"],
+		metrics["WritePolicy"],
+	).Set(1)
+
+	if level, ok := ParseRAIDLevel(metrics["Layout"]); ok {
+		c.vdiskLevel.WithLabelValues(vdiskLabel(vdisk), vdisk).Set(level)
+	} else {
+		c.vdiskLevel.DeletePartialMatch(prometheus.Labels{"vdisk_fqdd": vdisk})
+	}
+}
+
+// setProperty parses a racadm property into gauge, counting values that fail
+// to parse instead of exporting them as 0. Missing properties are skipped.
+func (c *Client) setProperty(gauge *prometheus.GaugeVec, object string, metrics map[string]string, property string, parse func(string) (float64, error)) {
+	value, ok := metrics[property]
+	if !ok {
+		return
+	}
+	parsed, err := parse(value)
+	if err != nil {
+		log.Printf("Error parsing %s for %s: %v", property, object, err)
+		c.parseErrors.WithLabelValues(object, property).Inc()
+		return
+	}
+	gauge.WithLabelValues(object).Set(parsed)
+}
+
+// setCurrentLabel exports value as the only series of vec whose label key is object.
+func setCurrentLabel(vec *prometheus.GaugeVec, key string, object string, value string) {
+	vec.DeletePartialMatch(prometheus.Labels{key: object})
+	if value != "" {
+		vec.WithLabelValues(object, value).Set(1)
 	}
 }
 
-func parseToFloat(value string) float64 {
-	parsed, _ := strconv.ParseFloat(strings.Fields(value)[0], 64)
-	return parsed
+func parseToFloat(value string) (float64, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty value")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// vdiskLabel returns the vdisk label value of the virtual disk with the given
+// FQDD: its controller part, e.g. "RAID.Integrated.1-1", as exported before
+// vdisk_fqdd was added. FQDDs without a controller part are used as is.
+func vdiskLabel(fqdd string) string {
+	if controller := controllerFromFQDD(fqdd); controller != "" {
+		return controller
+	}
+	return fqdd
+}
+
+// curryVDisk returns vec, labelled by vdisk and vdisk_fqdd, with the vdisk
+// label of the virtual disk fqdd set, so vdisk_fqdd is its only label left.
+func curryVDisk(vec *prometheus.GaugeVec, fqdd string) *prometheus.GaugeVec {
+	return vec.MustCurryWith(prometheus.Labels{"vdisk": vdiskLabel(fqdd)})
+}
+
+// controllerFromFQDD returns the controller part of a disk FQDD, e.g.
+// "RAID.Integrated.1-1" for "Disk.Virtual.0:RAID.Integrated.1-1" or
+// "Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1".
+func controllerFromFQDD(fqdd string) string {
+	parts := strings.Split(fqdd, ":")
+	if len(parts) < 2 {
+		return ""
+	}
+	return strings.TrimSpace(parts[len(parts)-1])
 }
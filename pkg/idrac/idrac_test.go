@@ -2,6 +2,7 @@ package idrac
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -22,6 +23,25 @@ func (e *MockCommandExecutor) ExecuteCommand(name string, args ...string) ([]byt
 	return []byte(e.MockOutput), nil
 }
 
+// CommandMockExecutor returns canned output keyed by the full command line,
+// e.g. "racadm raid get pdisks -o".
+type CommandMockExecutor struct {
+	Outputs map[string]string
+	Errors  map[string]error
+}
+
+func (e *CommandMockExecutor) ExecuteCommand(name string, args ...string) ([]byte, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	if err, ok := e.Errors[command]; ok {
+		return nil, err
+	}
+	output, ok := e.Outputs[command]
+	if !ok {
+		return nil, fmt.Errorf("unexpected command %q", command)
+	}
+	return []byte(output), nil
+}
+
 func TestGetRAIDStatus(t *testing.T) {
 	mockExecutor := &MockCommandExecutor{
 		MockOutput: `
@@ -39,7 +59,7 @@ Disk.Virtual.0:RAID.Integrated.1-0
 	}
 
 	registry := prometheus.NewRegistry()
-	client := NewClient(mockExecutor, registry)
+	client := NewClient(mockExecutor, registry, 5*time.Minute)
 	status, err := client.GetRAIDStatus()
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
@@ -47,17 +67,17 @@ Disk.Virtual.0:RAID.Integrated.1-0
 	if len(status) != 2 {
 		t.Fatalf("Expected 2 RAID statuses, got %d", len(status))
 	}
-	if status["RAID.Integrated.1-1"]["Layout"] != "Raid-10" {
-		t.Fatalf("Expected Layout to be Raid-10, got %s", status["RAID.Integrated.1-1"]["Layout"])
+	if status["Disk.Virtual.1:RAID.Integrated.1-1"]["Layout"] != "Raid-10" {
+		t.Fatalf("Expected Layout to be Raid-10, got %s", status["Disk.Virtual.1:RAID.Integrated.1-1"]["Layout"])
 	}
-	if status["RAID.Integrated.1-1"]["Size"] != "1787.50 GB" {
-		t.Fatalf("Expected Size to be 1787.50 GB, got %s", status["RAID.Integrated.1-1"]["Size"])
+	if status["Disk.Virtual.1:RAID.Integrated.1-1"]["Size"] != "1787.50 GB" {
+		t.Fatalf("Expected Size to be 1787.50 GB, got %s", status["Disk.Virtual.1:RAID.Integrated.1-1"]["Size"])
 	}
-	if status["RAID.Integrated.1-0"]["Layout"] != "Raid-1" {
-		t.Fatalf("Expected Layout to be Raid-1, got %s", status["RAID.Integrated.1-0"]["Layout"])
+	if status["Disk.Virtual.0:RAID.Integrated.1-0"]["Layout"] != "Raid-1" {
+		t.Fatalf("Expected Layout to be Raid-1, got %s", status["Disk.Virtual.0:RAID.Integrated.1-0"]["Layout"])
 	}
-	if status["RAID.Integrated.1-0"]["Size"] != "372.00 GB" {
-		t.Fatalf("Expected Size to be 372.00 GB, got %s", status["RAID.Integrated.1-0"]["Size"])
+	if status["Disk.Virtual.0:RAID.Integrated.1-0"]["Size"] != "372.00 GB" {
+		t.Fatalf("Expected Size to be 372.00 GB, got %s", status["Disk.Virtual.0:RAID.Integrated.1-0"]["Size"])
 	}
 }
 
@@ -71,7 +91,7 @@ Disk.Virtual.0:RAID.Integrated.1-1
 	}
 
 	registry := prometheus.NewRegistry()
-	client := NewClient(mockExecutor, registry)
+	client := NewClient(mockExecutor, registry, 5*time.Minute)
 	status, err := client.GetRAIDStatus()
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
@@ -79,11 +99,11 @@ Disk.Virtual.0:RAID.Integrated.1-1
 	if len(status) != 1 {
 		t.Fatalf("Expected 1 RAID status, got %d", len(status))
 	}
-	if status["RAID.Integrated.1-1"]["Layout"] != "Raid-1" {
-		t.Fatalf("Expected Layout to be Raid-1, got %s", status["RAID.Integrated.1-1"]["Layout"])
+	if status["Disk.Virtual.0:RAID.Integrated.1-1"]["Layout"] != "Raid-1" {
+		t.Fatalf("Expected Layout to be Raid-1, got %s", status["Disk.Virtual.0:RAID.Integrated.1-1"]["Layout"])
 	}
-	if status["RAID.Integrated.1-1"]["Status"] != "Ok" {
-		t.Fatalf("Expected Status to be Ok, got %s", status["RAID.Integrated.1-1"]["Status"])
+	if status["Disk.Virtual.0:RAID.Integrated.1-1"]["Status"] != "Ok" {
+		t.Fatalf("Expected Status to be Ok, got %s", status["Disk.Virtual.0:RAID.Integrated.1-1"]["Status"])
 	}
 }
 
@@ -93,7 +113,7 @@ func TestGetRAIDStatusError(t *testing.T) {
 	}
 
 	registry := prometheus.NewRegistry()
-	client := NewClient(mockExecutor, registry)
+	client := NewClient(mockExecutor, registry, 5*time.Minute)
 	_, err := client.GetRAIDStatus()
 	if err == nil {
 		t.Fatalf("Expected error, got none")
@@ -117,19 +137,14 @@ Disk.Virtual.0:RAID.Integrated.1-0
 	}
 
 	registry := prometheus.NewRegistry()
-	client := NewClient(mockExecutor, registry)
-
-	go client.UpdateMetrics()
-
-	// Allow some time for metrics to be updated
-	time.Sleep(1 * time.Second)
+	NewClient(mockExecutor, registry, 5*time.Minute)
 
 	// Test RAID status metrics
 	expectedStatus := `
-# HELP raid_status Status of the RAID controller
+# HELP raid_status Status of the RAID virtual disk (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)
 # TYPE raid_status gauge
-raid_status{vdisk="RAID.Integrated.1-1"} 1
-raid_status{vdisk="RAID.Integrated.1-0"} 1
+raid_status{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1
+raid_status{vdisk="RAID.Integrated.1-0",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-0"} 1
 `
 	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedStatus), "raid_status"); err != nil {
 		t.Fatalf("unexpected collecting result:\n%s", err)
@@ -139,8 +154,8 @@ raid_status{vdisk="RAID.Integrated.1-0"} 1
 	expectedRedundancy := `
 # HELP raid_redundancy Remaining redundancy of the RAID controller
 # TYPE raid_redundancy gauge
-raid_redundancy{vdisk="RAID.Integrated.1-1"} 1
-raid_redundancy{vdisk="RAID.Integrated.1-0"} 1
+raid_redundancy{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1
+raid_redundancy{vdisk="RAID.Integrated.1-0",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-0"} 1
 `
 	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedRedundancy), "raid_redundancy"); err != nil {
 		t.Fatalf("unexpected collecting result:\n%s", err)
@@ -148,23 +163,23 @@ raid_redundancy{vdisk="RAID.Integrated.1-0"} 1
 
 	// Test RAID size metrics
 	expectedSize := `
-# HELP raid_size Size of the RAID controller
-# TYPE raid_size gauge
-raid_size{vdisk="RAID.Integrated.1-1"} 1787.5
-raid_size{vdisk="RAID.Integrated.1-0"} 372
+# HELP raid_vdisk_size_bytes Size of the RAID virtual disk in bytes
+# TYPE raid_vdisk_size_bytes gauge
+raid_vdisk_size_bytes{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1.9193135104e+12
+raid_vdisk_size_bytes{vdisk="RAID.Integrated.1-0",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-0"} 3.99431958528e+11
 `
-	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedSize), "raid_size"); err != nil {
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedSize), "raid_vdisk_size_bytes"); err != nil {
 		t.Fatalf("unexpected collecting result:\n%s", err)
 	}
 
-	// Test RAID layout metrics
-	expectedLayout := `
-# HELP raid_layout Layout of the RAID controller
-# TYPE raid_layout gauge
-raid_layout{vdisk="RAID.Integrated.1-1"} 1
-raid_layout{vdisk="RAID.Integrated.1-0"} 1
+	// Test RAID level metrics
+	expectedLevel := `
+# HELP raid_vdisk_level RAID level of the virtual disk derived from its layout
+# TYPE raid_vdisk_level gauge
+raid_vdisk_level{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 10
+raid_vdisk_level{vdisk="RAID.Integrated.1-0",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-0"} 1
 `
-	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedLayout), "raid_layout"); err != nil {
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedLevel), "raid_vdisk_level"); err != nil {
 		t.Fatalf("unexpected collecting result:\n%s", err)
 	}
 }
@@ -181,18 +196,13 @@ Disk.Virtual.0:RAID.Integrated.1-1
 	}
 
 	registry := prometheus.NewRegistry()
-	client := NewClient(mockExecutor, registry)
-
-	go client.UpdateMetrics()
-
-	// Allow some time for metrics to be updated
-	time.Sleep(1 * time.Second)
+	NewClient(mockExecutor, registry, 5*time.Minute)
 
 	// Test RAID status metrics
 	expectedStatus := `
-# HELP raid_status Status of the RAID controller
+# HELP raid_status Status of the RAID virtual disk (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)
 # TYPE raid_status gauge
-raid_status{vdisk="RAID.Integrated.1-1"} 1
+raid_status{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
 `
 	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedStatus), "raid_status"); err != nil {
 		t.Fatalf("unexpected collecting result:\n%s", err)
@@ -202,7 +212,7 @@ raid_status{vdisk="RAID.Integrated.1-1"} 1
 	expectedRedundancy := `
 # HELP raid_redundancy Remaining redundancy of the RAID controller
 # TYPE raid_redundancy gauge
-raid_redundancy{vdisk="RAID.Integrated.1-1"} 1
+raid_redundancy{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
 `
 	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedRedundancy), "raid_redundancy"); err != nil {
 		t.Fatalf("unexpected collecting result:\n%s", err)
@@ -210,21 +220,382 @@ raid_redundancy{vdisk="RAID.Integrated.1-1"} 1
 
 	// Test RAID size metrics
 	expectedSize := `
-# HELP raid_size Size of the RAID controller
-# TYPE raid_size gauge
-raid_size{vdisk="RAID.Integrated.1-1"} 372
+# HELP raid_vdisk_size_bytes Size of the RAID virtual disk in bytes
+# TYPE raid_vdisk_size_bytes gauge
+raid_vdisk_size_bytes{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 3.99431958528e+11
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedSize), "raid_vdisk_size_bytes"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+
+	// Test RAID level metrics
+	expectedLevel := `
+# HELP raid_vdisk_level RAID level of the virtual disk derived from its layout
+# TYPE raid_vdisk_level gauge
+raid_vdisk_level{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedLevel), "raid_vdisk_level"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestUpdateMetricsVDisksOfOneController(t *testing.T) {
+	mockExecutor := &MockCommandExecutor{
+		MockOutput: `
+Disk.Virtual.0:RAID.Integrated.1-1
+   Layout                           = Raid-1
+   Status                           = Ok
+   RemainingRedundancy              = 1
+   Size                             = 446.63 GB
+Disk.Virtual.1:RAID.Integrated.1-1
+   Layout                           = Raid-5
+   Status                           = Degraded
+   RemainingRedundancy              = 0
+   Size                             = 3576.00 GB
+`,
+	}
+
+	registry := prometheus.NewRegistry()
+	NewClient(mockExecutor, registry, 5*time.Minute)
+
+	// Both virtual disks share the vdisk label of their controller and are
+	// told apart by vdisk_fqdd
+	expected := `
+# HELP raid_redundancy Remaining redundancy of the RAID controller
+# TYPE raid_redundancy gauge
+raid_redundancy{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+raid_redundancy{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 0
+# HELP raid_status Status of the RAID virtual disk (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)
+# TYPE raid_status gauge
+raid_status{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+raid_status{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 3
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "raid_redundancy", "raid_status"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestUpdateMetricsAbsentVDisk(t *testing.T) {
+	mockExecutor := &MockCommandExecutor{
+		MockOutput: `
+Disk.Virtual.1:RAID.Integrated.1-1
+   Layout                           = Raid-10
+   Status                           = Ok
+   RemainingRedundancy              = 1
+   Size                             = 1787.50 GB
+Disk.Virtual.0:RAID.Integrated.1-1
+   Layout                           = Raid-1
+   Status                           = Ok
+   RemainingRedundancy              = 1
+   Size                             = 372.00 GB
+`,
+	}
+
+	registry := prometheus.NewRegistry()
+	client := NewClient(mockExecutor, registry, 100*time.Millisecond)
+	if err := client.Update(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Simulate the deletion of Disk.Virtual.0
+	mockExecutor.MockOutput = `
+Disk.Virtual.1:RAID.Integrated.1-1
+   Layout                           = Raid-10
+   Status                           = Ok
+   RemainingRedundancy              = 1
+   Size                             = 1787.50 GB
+`
+	if err := client.Update(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expectedPresence := `
+# HELP raid_vdisk_present Presence of the RAID virtual disk, 0 once it is no longer reported by racadm
+# TYPE raid_vdisk_present gauge
+raid_vdisk_present{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1
+raid_vdisk_present{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedPresence), "raid_vdisk_present"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+
+	// Test that the metrics for the absent vdisk are kept during the grace period
+	expectedStatus := `
+# HELP raid_status Status of the RAID virtual disk (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)
+# TYPE raid_status gauge
+raid_status{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1
+raid_status{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedStatus), "raid_status"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+
+	// Once the grace period is over the series are removed
+	time.Sleep(200 * time.Millisecond)
+	if err := client.Update(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expectedPresence = `
+# HELP raid_vdisk_present Presence of the RAID virtual disk, 0 once it is no longer reported by racadm
+# TYPE raid_vdisk_present gauge
+raid_vdisk_present{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1
 `
-	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedSize), "raid_size"); err != nil {
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedPresence), "raid_vdisk_present"); err != nil {
 		t.Fatalf("unexpected collecting result:\n%s", err)
 	}
+	expectedStatus = `
+# HELP raid_status Status of the RAID virtual disk (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)
+# TYPE raid_status gauge
+raid_status{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedStatus), "raid_status"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+	if count := testutil.CollectAndCount(client.vdiskStatus); count != len(healthStatuses) {
+		t.Fatalf("Expected %d raid_vdisk_status series, got %d", len(healthStatuses), count)
+	}
+}
+
+func TestUpdateMetricsVDiskRecreated(t *testing.T) {
+	vdisks := `
+Disk.Virtual.0:RAID.Integrated.1-1
+   Layout                           = Raid-1
+   Status                           = Ok
+   Name                             = OS
+Disk.Virtual.1:RAID.Integrated.1-1
+   Layout                           = Raid-5
+   Status                           = Ok
+   Name                             = DATA
+`
+	mockExecutor := &MockCommandExecutor{MockOutput: vdisks}
+
+	registry := prometheus.NewRegistry()
+	client := NewClient(mockExecutor, registry, 100*time.Millisecond)
+	if err := client.Update(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Delete Disk.Virtual.1, the other virtual disk of the controller stays
+	mockExecutor.MockOutput = `
+Disk.Virtual.0:RAID.Integrated.1-1
+   Layout                           = Raid-1
+   Status                           = Ok
+   Name                             = OS
+`
+	if err := client.Update(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expectedPresence := `
+# HELP raid_vdisk_present Presence of the RAID virtual disk, 0 once it is no longer reported by racadm
+# TYPE raid_vdisk_present gauge
+raid_vdisk_present{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+raid_vdisk_present{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedPresence), "raid_vdisk_present"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+
+	// Once the grace period is over the series of Disk.Virtual.1 are removed
+	time.Sleep(200 * time.Millisecond)
+	if err := client.Update(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expectedInfo := `
+# HELP raid_vdisk_info Information about the RAID virtual disk, always 1
+# TYPE raid_vdisk_info gauge
+raid_vdisk_info{controller="RAID.Integrated.1-1",layout="Raid-1",name="OS",read_policy="",stripe_size="",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1",write_policy=""} 1
+# HELP raid_vdisk_present Presence of the RAID virtual disk, 0 once it is no longer reported by racadm
+# TYPE raid_vdisk_present gauge
+raid_vdisk_present{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedInfo), "raid_vdisk_info", "raid_vdisk_present"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+
+	// A virtual disk recreated with the same FQDD is reported again
+	mockExecutor.MockOutput = strings.Replace(vdisks, "Raid-5", "Raid-10", 1)
+	if err := client.Update(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expectedLevel := `
+# HELP raid_vdisk_level RAID level of the virtual disk derived from its layout
+# TYPE raid_vdisk_level gauge
+raid_vdisk_level{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+raid_vdisk_level{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 10
+# HELP raid_vdisk_present Presence of the RAID virtual disk, 0 once it is no longer reported by racadm
+# TYPE raid_vdisk_present gauge
+raid_vdisk_present{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+raid_vdisk_present{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedLevel), "raid_vdisk_level", "raid_vdisk_present"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestRemoteRacadmSource(t *testing.T) {
+	mockExecutor := &CommandMockExecutor{
+		Outputs: map[string]string{
+			"racadm -r idrac.example.com -u root -p calvin raid get vdisks -o -p " + vdiskProperties: `
+Disk.Virtual.0:RAID.Integrated.1-1
+   Layout                           = Raid-1
+   Status                           = Ok
+`,
+			"racadm -r idrac.example.com -u root -p calvin raid get pdisks --refkey Disk.Virtual.0:RAID.Integrated.1-1": `
+Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1
+`,
+		},
+	}
+	source := NewRemoteRacadmSource(mockExecutor, "idrac.example.com", "root", "calvin")
+
+	statuses, err := source.GetRAIDStatus()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if status := statuses["Disk.Virtual.0:RAID.Integrated.1-1"]["Status"]; status != "Ok" {
+		t.Fatalf("Expected status Ok, got %q", status)
+	}
+
+	members, err := source.GetVDiskMembers("Disk.Virtual.0:RAID.Integrated.1-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(members) != 1 || members[0] != "Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1" {
+		t.Fatalf("Unexpected members %v", members)
+	}
+}
+
+func TestCollectMinInterval(t *testing.T) {
+	mockExecutor := &CommandMockExecutor{
+		Outputs: map[string]string{
+			"racadm raid get vdisks -o -p " + vdiskProperties: "",
+			"racadm raid get pdisks -o":                       pdisksOutput,
+			"racadm raid get controllers -o":                  "",
+			"racadm raid get batteries -o":                    "",
+			"racadm raid get enclosures -o":                   "",
+			"racadm raid get emms -o":                         "",
+			"racadm raid get tempprobes -o":                   "",
+		},
+	}
+
+	registry := prometheus.NewRegistry()
+	client := NewClient(mockExecutor, registry, 5*time.Minute)
+	client.MinInterval = time.Hour
+
+	if count := testutil.CollectAndCount(client, "raid_pdisk_status"); count != 3 {
+		t.Fatalf("Expected 3 raid_pdisk_status series, got %d", count)
+	}
+
+	// Within MinInterval the previous results are served
+	mockExecutor.Outputs["racadm raid get pdisks -o"] = ""
+	if count := testutil.CollectAndCount(client, "raid_pdisk_status"); count != 3 {
+		t.Fatalf("Expected 3 cached raid_pdisk_status series, got %d", count)
+	}
+
+	// Once the cache expires the disks that are no longer reported disappear
+	client.MinInterval = 0
+	if count := testutil.CollectAndCount(client, "raid_pdisk_status"); count != 0 {
+		t.Fatalf("Expected no raid_pdisk_status series, got %d", count)
+	}
+}
+
+func TestUpdateListingFailureKeepsSeries(t *testing.T) {
+	vdisksCommand := "racadm raid get vdisks -o -p " + vdiskProperties
+	mockExecutor := &CommandMockExecutor{
+		Outputs: map[string]string{
+			vdisksCommand:               vdisksOutput,
+			"racadm raid get pdisks -o": pdisksOutput,
+			"racadm raid get pdisks --refkey Disk.Virtual.0:RAID.Integrated.1-1": `
+Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1
+`,
+		},
+		Errors: map[string]error{},
+	}
+
+	registry := prometheus.NewRegistry()
+	client := NewClient(mockExecutor, registry, 5*time.Minute)
+	if err := client.Update(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A failed listing keeps the virtual and physical disk series alike
+	mockExecutor.Errors[vdisksCommand] = errors.New("racadm: connection refused")
+	if err := client.Update(); err == nil {
+		t.Fatalf("Expected an error")
+	}
+	expected := `
+# HELP raid_pdisk_vdisk Membership of the physical disk in a RAID virtual disk, always 1
+# TYPE raid_pdisk_vdisk gauge
+raid_pdisk_vdisk{pdisk="Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+# HELP raid_vdisk_present Presence of the RAID virtual disk, 0 once it is no longer reported by racadm
+# TYPE raid_vdisk_present gauge
+raid_vdisk_present{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "raid_pdisk_vdisk", "raid_vdisk_present"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+	if count := testutil.CollectAndCount(client, "raid_pdisk_status"); count != 3 {
+		t.Fatalf("Expected 3 raid_pdisk_status series, got %d", count)
+	}
+}
+
+func TestCollectSelfMonitoring(t *testing.T) {
+	mockExecutor := &MockCommandExecutor{MockError: errors.New("racadm: connection refused")}
+
+	registry := prometheus.NewRegistry()
+	NewClient(mockExecutor, registry, 5*time.Minute)
+
+	expected := `
+# HELP dell_disk_exporter_collector_success Whether the last collection succeeded
+# TYPE dell_disk_exporter_collector_success gauge
+dell_disk_exporter_collector_success{collector="idrac"} 0
+# HELP dell_disk_exporter_command_errors_total Number of failed commands by reason (timeout, not_found, exit_status, error)
+# TYPE dell_disk_exporter_command_errors_total counter
+dell_disk_exporter_command_errors_total{collector="idrac",command="GetRAIDStatus",reason="error"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"dell_disk_exporter_collector_success", "dell_disk_exporter_command_errors_total"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+// countingExecutor counts the commands run through the wrapped executor.
+type countingExecutor struct {
+	CommandExecutor
+	calls int
+}
+
+func (e *countingExecutor) ExecuteCommand(name string, args ...string) ([]byte, error) {
+	e.calls++
+	return e.CommandExecutor.ExecuteCommand(name, args...)
+}
+
+func TestCollectBackoffOnFailure(t *testing.T) {
+	executor := &countingExecutor{CommandExecutor: &MockCommandExecutor{MockError: errors.New("racadm: timed out")}}
+
+	registry := prometheus.NewRegistry()
+	NewClient(executor, registry, 5*time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := registry.Gather(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	// Scrapes during the backoff are served without running racadm again
+	if executor.calls != 1 {
+		t.Fatalf("Expected racadm to run once, got %d calls", executor.calls)
+	}
 
-	// Test RAID layout metrics
-	expectedLayout := `
-# HELP raid_layout Layout of the RAID controller
-# TYPE raid_layout gauge
-raid_layout{vdisk="RAID.Integrated.1-1"} 1
+	expected := `
+# HELP dell_disk_exporter_collector_breaker_state State of the collector circuit breaker (0=Closed, 1=Open, 2=HalfOpen)
+# TYPE dell_disk_exporter_collector_breaker_state gauge
+dell_disk_exporter_collector_breaker_state{collector="idrac"} 0
+# HELP dell_disk_exporter_collector_consecutive_failures Number of consecutive failed collections
+# TYPE dell_disk_exporter_collector_consecutive_failures gauge
+dell_disk_exporter_collector_consecutive_failures{collector="idrac"} 1
 `
-	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedLayout), "raid_layout"); err != nil {
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"dell_disk_exporter_collector_breaker_state", "dell_disk_exporter_collector_consecutive_failures"); err != nil {
 		t.Fatalf("unexpected collecting result:\n%s", err)
 	}
 }
@@ -0,0 +1,22 @@
+package idrac
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseRAIDLevel converts a racadm Layout value such as "Raid-10" into its
+// numeric RAID level. The second return value is false when the layout does
+// not name a RAID level.
+func ParseRAIDLevel(layout string) (float64, bool) {
+	value := strings.ToLower(strings.TrimSpace(layout))
+	if !strings.HasPrefix(value, "raid") {
+		return 0, false
+	}
+	value = strings.TrimLeft(strings.TrimPrefix(value, "raid"), "-_ ")
+	level, err := strconv.Atoi(value)
+	if err != nil || level < 0 {
+		return 0, false
+	}
+	return float64(level), true
+}
@@ -0,0 +1,112 @@
+package idrac
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseRAIDLevel(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+		ok       bool
+	}{
+		{"Raid-0", 0, true},
+		{"Raid-1", 1, true},
+		{"Raid-5", 5, true},
+		{"Raid-6", 6, true},
+		{"Raid-10", 10, true},
+		{"Raid-50", 50, true},
+		{"Raid-60", 60, true},
+		{"RAID 1", 1, true},
+		{"Non-RAID", 0, false},
+		{"Raid-", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseRAIDLevel(tt.input)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("ParseRAIDLevel(%q) = (%v, %v), expected (%v, %v)", tt.input, got, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestUpdateMetricsVDiskInfo(t *testing.T) {
+	mockExecutor := &MockCommandExecutor{
+		MockOutput: `
+Disk.Virtual.0:RAID.Integrated.1-1
+   Layout                           = Raid-5
+   Status                           = Ok
+   RemainingRedundancy              = 1
+   Size                             = 3725.00 GB
+   Name                             = DATA
+   StripeSize                       = 256K
+   ReadPolicy                       = Read Ahead
+   WritePolicy                      = Write Back
+Disk.Virtual.1:RAID.Integrated.1-1
+   Layout                           = Raid-1
+   Status                           = Ok
+   RemainingRedundancy              = 1
+   Size                             = 446.63 GB
+   Name                             = OS
+   StripeSize                       = 64K
+   ReadPolicy                       = No Read Ahead
+   WritePolicy                      = Write Through
+`,
+	}
+
+	registry := prometheus.NewRegistry()
+	NewClient(mockExecutor, registry, 5*time.Minute)
+
+	// Both virtual disks of the controller are exported
+	expectedInfo := `
+# HELP raid_vdisk_info Information about the RAID virtual disk, always 1
+# TYPE raid_vdisk_info gauge
+raid_vdisk_info{controller="RAID.Integrated.1-1",layout="Raid-5",name="DATA",read_policy="Read Ahead",stripe_size="256K",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1",write_policy="Write Back"} 1
+raid_vdisk_info{controller="RAID.Integrated.1-1",layout="Raid-1",name="OS",read_policy="No Read Ahead",stripe_size="64K",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1",write_policy="Write Through"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedInfo), "raid_vdisk_info"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+
+	expectedLevel := `
+# HELP raid_vdisk_level RAID level of the virtual disk derived from its layout
+# TYPE raid_vdisk_level gauge
+raid_vdisk_level{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 5
+raid_vdisk_level{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedLevel), "raid_vdisk_level"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestSetInfoReplacesChangedPolicy(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	client := NewClient(&MockCommandExecutor{}, registry, 5*time.Minute)
+
+	metrics := map[string]string{
+		"FQDD":        "Disk.Virtual.0:RAID.Integrated.1-1",
+		"Layout":      "Raid-1",
+		"Name":        "OS",
+		"StripeSize":  "64K",
+		"ReadPolicy":  "No Read Ahead",
+		"WritePolicy": "Write Back",
+	}
+	client.setInfo("Disk.Virtual.0:RAID.Integrated.1-1", metrics)
+	metrics["WritePolicy"] = "Write Through"
+	client.setInfo("Disk.Virtual.0:RAID.Integrated.1-1", metrics)
+
+	expectedInfo := `
+# HELP raid_vdisk_info Information about the RAID virtual disk, always 1
+# TYPE raid_vdisk_info gauge
+raid_vdisk_info{controller="RAID.Integrated.1-1",layout="Raid-1",name="OS",read_policy="No Read Ahead",stripe_size="64K",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1",write_policy="Write Through"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedInfo), "raid_vdisk_info"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
@@ -0,0 +1,239 @@
+package idrac
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pdiskMetrics holds the gauges exported for physical disks.
+type pdiskMetrics struct {
+	status            *prometheus.GaugeVec
+	state             *prometheus.GaugeVec
+	predictiveFailure *prometheus.GaugeVec
+	sizeBytes         *prometheus.GaugeVec
+	hotSpare          *prometheus.GaugeVec
+	writeEndurance    *prometheus.GaugeVec
+	info              *prometheus.GaugeVec
+	vdisk             *prometheus.GaugeVec
+}
+
+func newPDiskMetrics() *pdiskMetrics {
+	m := &pdiskMetrics{
+		status: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_pdisk_status",
+				Help: "Status of the physical disk (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)",
+			},
+			[]string{"pdisk"},
+		),
+		state: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_pdisk_state",
+				Help: "State of the physical disk as reported by racadm, 1 for the current state",
+			},
+			[]string{"pdisk", "state"},
+		),
+		predictiveFailure: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_pdisk_predictive_failure",
+				Help: "Whether the controller predicts a failure of the physical disk",
+			},
+			[]string{"pdisk"},
+		),
+		sizeBytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_pdisk_size_bytes",
+				Help: "Size of the physical disk in bytes",
+			},
+			[]string{"pdisk"},
+		),
+		hotSpare: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_pdisk_hot_spare",
+				Help: "Hot spare role of the physical disk (None, Dedicated, Global), 1 for the current role",
+			},
+			[]string{"pdisk", "role"},
+		),
+		writeEndurance: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_pdisk_remaining_write_endurance_ratio",
+				Help: "Remaining rated write endurance of the physical disk, from 0 to 1",
+			},
+			[]string{"pdisk"},
+		),
+		info: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_pdisk_info",
+				Help: "Information about the physical disk, always 1",
+			},
+			[]string{"pdisk", "controller", "name", "media_type", "bus_protocol", "manufacturer", "model", "serial", "firmware"},
+		),
+		vdisk: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_pdisk_vdisk",
+				Help: "Membership of the physical disk in a RAID virtual disk, always 1",
+			},
+			[]string{"pdisk", "vdisk", "vdisk_fqdd"},
+		),
+	}
+
+	return m
+}
+
+func (m *pdiskMetrics) vecs() []*prometheus.GaugeVec {
+	return []*prometheus.GaugeVec{
+		m.status,
+		m.state,
+		m.predictiveFailure,
+		m.sizeBytes,
+		m.hotSpare,
+		m.writeEndurance,
+		m.info,
+		m.vdisk,
+	}
+}
+
+// GetPhysicalDisks returns the racadm properties of every physical disk keyed by its FQDD,
+// e.g. "Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1".
+func (r *RacadmSource) GetPhysicalDisks() (map[string]map[string]string, error) {
+	return r.getRacadmObjects("pdisks", func(fqdd string) string {
+		if !strings.HasPrefix(fqdd, "Disk.") || strings.HasPrefix(fqdd, "Disk.Virtual") {
+			return ""
+		}
+		return fqdd
+	})
+}
+
+// GetVDiskMembers returns the FQDDs of the physical disks backing the virtual disk vdiskFQDD.
+func (r *RacadmSource) GetVDiskMembers(vdiskFQDD string) ([]string, error) {
+	output, err := r.racadm("raid", "get", "pdisks", "--refkey", vdiskFQDD)
+	if err != nil {
+		log.Printf("Error executing racadm command: %v", err)
+		return nil, err
+	}
+
+	var members []string
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Disk.") && !strings.HasPrefix(line, "Disk.Virtual") {
+			members = append(members, line)
+		}
+	}
+	return members, nil
+}
+
+// PDiskSerials returns the FQDD of every physical disk of source keyed by its
+// serial number, so drives read through other tools can be matched to their pdisk.
+// Serial numbers are trimmed and uppercased.
+func PDiskSerials(source Source) (map[string]string, error) {
+	pdisks, err := source.GetPhysicalDisks()
+	if err != nil {
+		return nil, err
+	}
+
+	serials := make(map[string]string, len(pdisks))
+	for fqdd, properties := range pdisks {
+		if serial := strings.ToUpper(strings.TrimSpace(properties["SerialNumber"])); serial != "" {
+			serials[serial] = fqdd
+		}
+	}
+	return serials, nil
+}
+
+// updatePhysicalDiskMetrics refreshes the physical disk metrics. vdisks is the
+// result of GetRAIDStatus, keyed by virtual disk FQDD, and is used to resolve
+// the membership of every virtual disk.
+func (c *Client) updatePhysicalDiskMetrics(vdisks map[string]map[string]string) {
+	pdisks, err := c.GetPhysicalDisks()
+	if err != nil {
+		log.Printf("Error fetching physical disks: %v", err)
+		return
+	}
+
+	for pdisk, properties := range pdisks {
+		c.pdisks.status.WithLabelValues(pdisk).Set(float64(ParseHealthStatus(properties["Status"])))
+		setCurrentLabel(c.pdisks.state, "pdisk", pdisk, properties["State"])
+		setCurrentLabel(c.pdisks.hotSpare, "pdisk", pdisk, hotSpareRole(properties["Hotspare"]))
+		c.setProperty(c.pdisks.predictiveFailure, pdisk, properties, "FailurePredicted", parseYesNo)
+		c.setProperty(c.pdisks.sizeBytes, pdisk, properties, "Size", func(value string) (float64, error) {
+			return ParseSize(value, BinarySizeBase)
+		})
+		c.setPDiskRebuild(pdisk, properties)
+		if !notApplicable(properties["RemainingRatedWriteEndurance"]) {
+			c.setProperty(c.pdisks.writeEndurance, pdisk, properties, "RemainingRatedWriteEndurance", parsePercentRatio)
+		}
+
+		c.pdisks.info.DeletePartialMatch(prometheus.Labels{"pdisk": pdisk})
+		c.pdisks.info.WithLabelValues(
+			pdisk,
+			controllerFromFQDD(pdisk),
+			properties["Name"],
+			properties["MediaType"],
+			properties["BusProtocol"],
+			properties["Manufacturer"],
+			properties["ProductId"],
+			properties["SerialNumber"],
+			properties["Revision"],
+		).Set(1)
+	}
+
+	for vdisk, properties := range vdisks {
+		members, err := c.GetVDiskMembers(properties["FQDD"])
+		if err != nil {
+			log.Printf("Error fetching members of %s: %v", vdisk, err)
+			continue
+		}
+		for _, pdisk := range members {
+			c.pdisks.vdisk.WithLabelValues(pdisk, vdiskLabel(vdisk), vdisk).Set(1)
+		}
+	}
+}
+
+// hotSpareRole normalizes the racadm Hotspare property, which is NO for regular disks.
+func hotSpareRole(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return ""
+	case "no", "none":
+		return "None"
+	case "dedicated":
+		return "Dedicated"
+	case "global":
+		return "Global"
+	default:
+		return value
+	}
+}
+
+func parseYesNo(value string) (float64, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "true":
+		return 1, nil
+	case "no", "false":
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("invalid boolean %q", value)
+	}
+}
+
+// parsePercentRatio converts "98 %" into 0.98.
+func parsePercentRatio(value string) (float64, error) {
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "%"))
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid percentage %q: %w", value, err)
+	}
+	return parsed / 100, nil
+}
+
+func notApplicable(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "not applicable", "n/a", "na":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,259 @@
+package idrac
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const pdisksOutput = `
+Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1
+   Status                           = Ok
+   DeviceDescription                = Disk 0 in Backplane 1 of Integrated RAID Controller 1
+   RollupStatus                     = Ok
+   Name                             = Physical Disk 0:1:0
+   State                            = Online
+   OperationState                   = Not Applicable
+   PowerStatus                      = Spun-Up
+   Size                             = 446.63 GB
+   FailurePredicted                 = NO
+   RemainingRatedWriteEndurance     = 98 %
+   SecurityStatus                   = Not Capable
+   BusProtocol                      = SATA
+   MediaType                        = SSD
+   UsedRaidDiskSpace                = 446.63 GB
+   AvailableRaidDiskSpace           = 0.00 GB
+   Hotspare                         = NO
+   Manufacturer                     = INTEL
+   ProductId                        = SSDSC2KB480G8R
+   Revision                         = XCV1DL67
+   SerialNumber                     = PHYF000000AA480BGN
+Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1
+   Status                           = Non-Critical
+   DeviceDescription                = Disk 1 in Backplane 1 of Integrated RAID Controller 1
+   RollupStatus                     = Non-Critical
+   Name                             = Physical Disk 0:1:1
+   State                            = Online
+   OperationState                   = Not Applicable
+   PowerStatus                      = Spun-Up
+   Size                             = 1862.50 GB
+   FailurePredicted                 = YES
+   RemainingRatedWriteEndurance     = Not Applicable
+   BusProtocol                      = SAS
+   MediaType                        = HDD
+   Hotspare                         = NO
+   Manufacturer                     = SEAGATE
+   ProductId                        = ST2000NM0135
+   Revision                         = DSF7
+   SerialNumber                     = ZC200000
+Disk.Bay.2:Enclosure.Internal.0-1:RAID.Integrated.1-1
+   Status                           = Ok
+   Name                             = Physical Disk 0:1:2
+   State                            = Ready
+   Size                             = 1862.50 GB
+   FailurePredicted                 = NO
+   RemainingRatedWriteEndurance     = Not Applicable
+   BusProtocol                      = SAS
+   MediaType                        = HDD
+   Hotspare                         = Global
+   Manufacturer                     = SEAGATE
+   ProductId                        = ST2000NM0135
+   Revision                         = DSF7
+   SerialNumber                     = ZC200001
+`
+
+const vdisksOutput = `
+Disk.Virtual.0:RAID.Integrated.1-1
+   Layout                           = Raid-1
+   Status                           = Non-Critical
+   RemainingRedundancy              = 1
+   Size                             = 446.63 GB
+   Name                             = OS
+   StripeSize                       = 64K
+   ReadPolicy                       = No Read Ahead
+   WritePolicy                      = Write Back
+`
+
+func TestGetPhysicalDisks(t *testing.T) {
+	mockExecutor := &CommandMockExecutor{
+		Outputs: map[string]string{"racadm raid get pdisks -o": pdisksOutput},
+	}
+
+	registry := prometheus.NewRegistry()
+	client := NewClient(mockExecutor, registry, 5*time.Minute)
+	pdisks, err := client.GetPhysicalDisks()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(pdisks) != 3 {
+		t.Fatalf("Expected 3 physical disks, got %d", len(pdisks))
+	}
+	pdisk := pdisks["Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1"]
+	if pdisk["FailurePredicted"] != "YES" {
+		t.Fatalf("Expected FailurePredicted to be YES, got %s", pdisk["FailurePredicted"])
+	}
+	if pdisk["MediaType"] != "HDD" {
+		t.Fatalf("Expected MediaType to be HDD, got %s", pdisk["MediaType"])
+	}
+}
+
+func TestPDiskSerials(t *testing.T) {
+	mockExecutor := &CommandMockExecutor{
+		Outputs: map[string]string{"racadm raid get pdisks -o": pdisksOutput},
+	}
+
+	serials, err := PDiskSerials(NewRacadmSource(mockExecutor))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if fqdd := serials["PHYF000000AA480BGN"]; fqdd != "Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1" {
+		t.Fatalf("Unexpected pdisk %q for PHYF000000AA480BGN", fqdd)
+	}
+	if fqdd := serials["ZC200000"]; fqdd != "Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1" {
+		t.Fatalf("Unexpected pdisk %q for ZC200000", fqdd)
+	}
+}
+
+func TestGetVDiskMembers(t *testing.T) {
+	mockExecutor := &CommandMockExecutor{
+		Outputs: map[string]string{
+			"racadm raid get pdisks --refkey Disk.Virtual.0:RAID.Integrated.1-1": `
+Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1
+Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1
+`,
+		},
+	}
+
+	registry := prometheus.NewRegistry()
+	client := NewClient(mockExecutor, registry, 5*time.Minute)
+	members, err := client.GetVDiskMembers("Disk.Virtual.0:RAID.Integrated.1-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(members) != 2 || members[1] != "Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1" {
+		t.Fatalf("Unexpected members: %v", members)
+	}
+}
+
+func TestGetPhysicalDisksError(t *testing.T) {
+	mockExecutor := &CommandMockExecutor{
+		Errors: map[string]error{"racadm raid get pdisks -o": errors.New("command error")},
+	}
+
+	registry := prometheus.NewRegistry()
+	client := NewClient(mockExecutor, registry, 5*time.Minute)
+	if _, err := client.GetPhysicalDisks(); err == nil {
+		t.Fatalf("Expected error, got none")
+	}
+}
+
+func TestUpdateMetricsPhysicalDisks(t *testing.T) {
+	mockExecutor := &CommandMockExecutor{
+		Outputs: map[string]string{
+			"racadm raid get vdisks -o -p " + vdiskProperties: vdisksOutput,
+			"racadm raid get pdisks -o":                       pdisksOutput,
+			"racadm raid get pdisks --refkey Disk.Virtual.0:RAID.Integrated.1-1": `
+Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1
+Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1
+`,
+		},
+	}
+
+	registry := prometheus.NewRegistry()
+	NewClient(mockExecutor, registry, 5*time.Minute)
+
+	expected := `
+# HELP raid_pdisk_hot_spare Hot spare role of the physical disk (None, Dedicated, Global), 1 for the current role
+# TYPE raid_pdisk_hot_spare gauge
+raid_pdisk_hot_spare{pdisk="Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1",role="None"} 1
+raid_pdisk_hot_spare{pdisk="Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1",role="None"} 1
+raid_pdisk_hot_spare{pdisk="Disk.Bay.2:Enclosure.Internal.0-1:RAID.Integrated.1-1",role="Global"} 1
+# HELP raid_pdisk_info Information about the physical disk, always 1
+# TYPE raid_pdisk_info gauge
+raid_pdisk_info{bus_protocol="SAS",controller="RAID.Integrated.1-1",firmware="DSF7",manufacturer="SEAGATE",media_type="HDD",model="ST2000NM0135",name="Physical Disk 0:1:1",pdisk="Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1",serial="ZC200000"} 1
+raid_pdisk_info{bus_protocol="SAS",controller="RAID.Integrated.1-1",firmware="DSF7",manufacturer="SEAGATE",media_type="HDD",model="ST2000NM0135",name="Physical Disk 0:1:2",pdisk="Disk.Bay.2:Enclosure.Internal.0-1:RAID.Integrated.1-1",serial="ZC200001"} 1
+raid_pdisk_info{bus_protocol="SATA",controller="RAID.Integrated.1-1",firmware="XCV1DL67",manufacturer="INTEL",media_type="SSD",model="SSDSC2KB480G8R",name="Physical Disk 0:1:0",pdisk="Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1",serial="PHYF000000AA480BGN"} 1
+# HELP raid_pdisk_predictive_failure Whether the controller predicts a failure of the physical disk
+# TYPE raid_pdisk_predictive_failure gauge
+raid_pdisk_predictive_failure{pdisk="Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1"} 0
+raid_pdisk_predictive_failure{pdisk="Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1"} 1
+raid_pdisk_predictive_failure{pdisk="Disk.Bay.2:Enclosure.Internal.0-1:RAID.Integrated.1-1"} 0
+# HELP raid_pdisk_remaining_write_endurance_ratio Remaining rated write endurance of the physical disk, from 0 to 1
+# TYPE raid_pdisk_remaining_write_endurance_ratio gauge
+raid_pdisk_remaining_write_endurance_ratio{pdisk="Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1"} 0.98
+# HELP raid_pdisk_state State of the physical disk as reported by racadm, 1 for the current state
+# TYPE raid_pdisk_state gauge
+raid_pdisk_state{pdisk="Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1",state="Online"} 1
+raid_pdisk_state{pdisk="Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1",state="Online"} 1
+raid_pdisk_state{pdisk="Disk.Bay.2:Enclosure.Internal.0-1:RAID.Integrated.1-1",state="Ready"} 1
+# HELP raid_pdisk_status Status of the physical disk (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)
+# TYPE raid_pdisk_status gauge
+raid_pdisk_status{pdisk="Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1"} 1
+raid_pdisk_status{pdisk="Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1"} 3
+raid_pdisk_status{pdisk="Disk.Bay.2:Enclosure.Internal.0-1:RAID.Integrated.1-1"} 1
+# HELP raid_pdisk_vdisk Membership of the physical disk in a RAID virtual disk, always 1
+# TYPE raid_pdisk_vdisk gauge
+raid_pdisk_vdisk{pdisk="Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+raid_pdisk_vdisk{pdisk="Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"raid_pdisk_hot_spare", "raid_pdisk_info", "raid_pdisk_predictive_failure",
+		"raid_pdisk_remaining_write_endurance_ratio", "raid_pdisk_state", "raid_pdisk_status", "raid_pdisk_vdisk"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+
+	expectedSize := `
+# HELP raid_pdisk_size_bytes Size of the physical disk in bytes
+# TYPE raid_pdisk_size_bytes gauge
+raid_pdisk_size_bytes{pdisk="Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1"} 4.7956531085312e+11
+raid_pdisk_size_bytes{pdisk="Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1"} 1.9998441472e+12
+raid_pdisk_size_bytes{pdisk="Disk.Bay.2:Enclosure.Internal.0-1:RAID.Integrated.1-1"} 1.9998441472e+12
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedSize), "raid_pdisk_size_bytes"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestUpdateMetricsVDiskMembership(t *testing.T) {
+	mockExecutor := &CommandMockExecutor{
+		Outputs: map[string]string{
+			"racadm raid get vdisks -o -p " + vdiskProperties: `
+Disk.Virtual.0:RAID.Integrated.1-1
+   Layout                           = Raid-1
+   Status                           = Ok
+Disk.Virtual.1:RAID.Integrated.1-1
+   Layout                           = Raid-1
+   Status                           = Ok
+`,
+			"racadm raid get pdisks -o": "",
+			"racadm raid get pdisks --refkey Disk.Virtual.0:RAID.Integrated.1-1": `
+Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1
+Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1
+`,
+			"racadm raid get pdisks --refkey Disk.Virtual.1:RAID.Integrated.1-1": `
+Disk.Bay.2:Enclosure.Internal.0-1:RAID.Integrated.1-1
+Disk.Bay.3:Enclosure.Internal.0-1:RAID.Integrated.1-1
+`,
+		},
+	}
+
+	registry := prometheus.NewRegistry()
+	NewClient(mockExecutor, registry, 5*time.Minute)
+
+	// Members of both virtual disks of the controller are reported
+	expected := `
+# HELP raid_pdisk_vdisk Membership of the physical disk in a RAID virtual disk, always 1
+# TYPE raid_pdisk_vdisk gauge
+raid_pdisk_vdisk{pdisk="Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+raid_pdisk_vdisk{pdisk="Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+raid_pdisk_vdisk{pdisk="Disk.Bay.2:Enclosure.Internal.0-1:RAID.Integrated.1-1",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1
+raid_pdisk_vdisk{pdisk="Disk.Bay.3:Enclosure.Internal.0-1:RAID.Integrated.1-1",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "raid_pdisk_vdisk"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
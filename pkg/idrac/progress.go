@@ -0,0 +1,196 @@
+package idrac
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// progressMetrics holds the gauges exported for long-running RAID operations
+// such as rebuilds, background initialization and consistency checks.
+type progressMetrics struct {
+	vdiskProgress *prometheus.GaugeVec
+	vdiskETA      *prometheus.GaugeVec
+	pdiskProgress *prometheus.GaugeVec
+	pdiskETA      *prometheus.GaugeVec
+	tracker       *progressTracker
+}
+
+func newProgressMetrics() *progressMetrics {
+	m := &progressMetrics{
+		vdiskProgress: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_vdisk_operation_progress_ratio",
+				Help: "Progress of the operation running on the RAID virtual disk, from 0 to 1",
+			},
+			[]string{"vdisk", "vdisk_fqdd", "operation"},
+		),
+		vdiskETA: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_vdisk_operation_eta_seconds",
+				Help: "Estimated time until the operation running on the RAID virtual disk completes",
+			},
+			[]string{"vdisk", "vdisk_fqdd", "operation"},
+		),
+		pdiskProgress: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_pdisk_rebuild_progress_ratio",
+				Help: "Progress of the rebuild of the physical disk, from 0 to 1",
+			},
+			[]string{"pdisk"},
+		),
+		pdiskETA: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "raid_pdisk_rebuild_eta_seconds",
+				Help: "Estimated time until the rebuild of the physical disk completes",
+			},
+			[]string{"pdisk"},
+		),
+		tracker: newProgressTracker(time.Now),
+	}
+
+	return m
+}
+
+func (m *progressMetrics) vecs() []*prometheus.GaugeVec {
+	return []*prometheus.GaugeVec{
+		m.vdiskProgress,
+		m.vdiskETA,
+		m.pdiskProgress,
+		m.pdiskETA,
+	}
+}
+
+// setVDiskOperation exports the progress of the operation reported for vdisk,
+// or removes its series when no operation is running.
+func (c *Client) setVDiskOperation(vdisk string, metrics map[string]string) {
+	operation := operationName(firstProperty(metrics, "OperationName", "OperationalState"))
+	ratio, ok := c.operationProgress(vdisk, metrics, operation)
+
+	c.progress.vdiskProgress.DeletePartialMatch(prometheus.Labels{"vdisk_fqdd": vdisk})
+	c.progress.vdiskETA.DeletePartialMatch(prometheus.Labels{"vdisk_fqdd": vdisk})
+	if !ok {
+		c.progress.tracker.forget("vdisk/" + vdisk + "/")
+		return
+	}
+
+	c.progress.vdiskProgress.WithLabelValues(vdiskLabel(vdisk), vdisk, operation).Set(ratio)
+	if eta, ok := c.progress.tracker.observe("vdisk/"+vdisk+"/"+operation, ratio); ok {
+		c.progress.vdiskETA.WithLabelValues(vdiskLabel(vdisk), vdisk, operation).Set(eta)
+	}
+}
+
+// setPDiskRebuild exports the rebuild progress of pdisk, or removes its series
+// when the disk is not rebuilding.
+func (c *Client) setPDiskRebuild(pdisk string, properties map[string]string) {
+	operation := operationName(firstProperty(properties, "OperationName", "OperationState"))
+	ratio, ok := c.operationProgress(pdisk, properties, operation)
+	if ok && !strings.Contains(strings.ToLower(operation), "rebuild") {
+		ok = false
+	}
+
+	if !ok {
+		c.progress.pdiskProgress.DeleteLabelValues(pdisk)
+		c.progress.pdiskETA.DeleteLabelValues(pdisk)
+		c.progress.tracker.forget("pdisk/" + pdisk + "/")
+		return
+	}
+
+	c.progress.pdiskProgress.WithLabelValues(pdisk).Set(ratio)
+	if eta, ok := c.progress.tracker.observe("pdisk/"+pdisk+"/rebuild", ratio); ok {
+		c.progress.pdiskETA.WithLabelValues(pdisk).Set(eta)
+	} else {
+		c.progress.pdiskETA.DeleteLabelValues(pdisk)
+	}
+}
+
+// operationProgress returns the completion ratio of operation, or false when
+// no operation is running or its progress could not be parsed.
+func (c *Client) operationProgress(object string, properties map[string]string, operation string) (float64, bool) {
+	if operation == "" {
+		return 0, false
+	}
+	value := firstProperty(properties, "OperationPercentComplete", "ProgressPercent")
+	if value == "" || notApplicable(value) {
+		return 0, false
+	}
+	ratio, err := parsePercentRatio(value)
+	if err != nil {
+		log.Printf("Error parsing operation progress for %s: %v", object, err)
+		c.parseErrors.WithLabelValues(object, "OperationPercentComplete").Inc()
+		return 0, false
+	}
+	return ratio, true
+}
+
+// operationName normalizes the racadm operation property, which reports
+// "Not applicable" or "None" when the object is idle.
+func operationName(value string) string {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case "", "none", "not applicable", "n/a", "ready", "online":
+		return ""
+	}
+	return value
+}
+
+// progressTracker estimates the time left for an operation from the progress
+// observed between two updates.
+type progressTracker struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	samples map[string]progressSample
+}
+
+type progressSample struct {
+	ratio float64
+	at    time.Time
+}
+
+func newProgressTracker(now func() time.Time) *progressTracker {
+	return &progressTracker{
+		now:     now,
+		samples: make(map[string]progressSample),
+	}
+}
+
+// observe records ratio for key and returns the estimated seconds until
+// completion. The estimate is only available once progress has been made
+// since the first observation.
+func (t *progressTracker) observe(key string, ratio float64) (float64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	first, found := t.samples[key]
+	if !found || ratio < first.ratio {
+		// Keep the first sample of the operation so the rate is averaged over its whole run.
+		t.samples[key] = progressSample{ratio: ratio, at: now}
+		return 0, false
+	}
+	if ratio >= 1 {
+		return 0, true
+	}
+
+	elapsed := now.Sub(first.at).Seconds()
+	if ratio == first.ratio || elapsed <= 0 {
+		return 0, false
+	}
+	rate := (ratio - first.ratio) / elapsed
+	return (1 - ratio) / rate, true
+}
+
+// forget drops every sample whose key starts with prefix.
+func (t *progressTracker) forget(prefix string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key := range t.samples {
+		if strings.HasPrefix(key, prefix) {
+			delete(t.samples, key)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package idrac
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func readTestdata(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("Error reading testdata: %v", err)
+	}
+	return string(data)
+}
+
+func TestOperationProgressDuringRebuild(t *testing.T) {
+	// Three virtual disks on one controller: OS rebuilding onto Disk.Bay.1,
+	// DATA initializing in the background and LOGS idle.
+	mockExecutor := &CommandMockExecutor{
+		Outputs: map[string]string{
+			"racadm raid get vdisks -o -p " + vdiskProperties: readTestdata(t, "rebuild_vdisks.txt"),
+			"racadm raid get pdisks -o":                       readTestdata(t, "rebuild_pdisks.txt"),
+			"racadm raid get pdisks --refkey Disk.Virtual.0:RAID.Integrated.1-1": `
+Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1
+Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1
+`,
+			"racadm raid get pdisks --refkey Disk.Virtual.1:RAID.Integrated.1-1": `
+Disk.Bay.2:Enclosure.Internal.0-1:RAID.Integrated.1-1
+Disk.Bay.3:Enclosure.Internal.0-1:RAID.Integrated.1-1
+Disk.Bay.4:Enclosure.Internal.0-1:RAID.Integrated.1-1
+`,
+			"racadm raid get pdisks --refkey Disk.Virtual.2:RAID.Integrated.1-1": `
+Disk.Bay.5:Enclosure.Internal.0-1:RAID.Integrated.1-1
+Disk.Bay.6:Enclosure.Internal.0-1:RAID.Integrated.1-1
+`,
+			"racadm raid get controllers -o": "",
+			"racadm raid get batteries -o":   "",
+			"racadm raid get enclosures -o":  "",
+			"racadm raid get emms -o":        "",
+			"racadm raid get tempprobes -o":  "",
+		},
+	}
+
+	registry := prometheus.NewRegistry()
+	NewClient(mockExecutor, registry, 5*time.Minute)
+
+	expected := `
+# HELP raid_pdisk_rebuild_progress_ratio Progress of the rebuild of the physical disk, from 0 to 1
+# TYPE raid_pdisk_rebuild_progress_ratio gauge
+raid_pdisk_rebuild_progress_ratio{pdisk="Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1"} 0.37
+# HELP raid_vdisk_operation_progress_ratio Progress of the operation running on the RAID virtual disk, from 0 to 1
+# TYPE raid_vdisk_operation_progress_ratio gauge
+raid_vdisk_operation_progress_ratio{operation="Background Initialization",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 0.12
+raid_vdisk_operation_progress_ratio{operation="Rebuilding",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 0.37
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"raid_pdisk_rebuild_progress_ratio", "raid_vdisk_operation_progress_ratio"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+	if count := testutil.CollectAndCount(registry, "raid_vdisk_present"); count != 3 {
+		t.Fatalf("Expected 3 raid_vdisk_present series, got %d", count)
+	}
+}
+
+func TestOperationProgressETA(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	client := NewClient(&MockCommandExecutor{}, registry, 5*time.Minute)
+
+	now := time.Date(2024, 6, 19, 12, 0, 0, 0, time.UTC)
+	client.progress.tracker.now = func() time.Time { return now }
+
+	pdisk := "Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1"
+	properties := map[string]string{
+		"OperationState":           "Rebuilding",
+		"OperationName":            "Rebuild",
+		"OperationPercentComplete": "20 %",
+	}
+	client.setPDiskRebuild(pdisk, properties)
+	if count := testutil.CollectAndCount(client.progress.pdiskETA); count != 0 {
+		t.Fatalf("Expected no ETA after the first sample, got %d series", count)
+	}
+
+	// 10% in 10 minutes leaves 70% to go, i.e. 70 minutes.
+	now = now.Add(10 * time.Minute)
+	properties["OperationPercentComplete"] = "30 %"
+	client.setPDiskRebuild(pdisk, properties)
+	if eta := testutil.ToFloat64(client.progress.pdiskETA.WithLabelValues(pdisk)); eta < 4199 || eta > 4201 {
+		t.Fatalf("Expected ETA of 4200 seconds, got %v", eta)
+	}
+
+	// Once the rebuild finishes the series go away.
+	properties["OperationState"] = "Not Applicable"
+	properties["OperationName"] = "None"
+	properties["OperationPercentComplete"] = "Not Applicable"
+	client.setPDiskRebuild(pdisk, properties)
+	if count := testutil.CollectAndCount(client.progress.pdiskProgress); count != 0 {
+		t.Fatalf("Expected no progress series after the rebuild, got %d", count)
+	}
+	if count := testutil.CollectAndCount(client.progress.pdiskETA); count != 0 {
+		t.Fatalf("Expected no ETA series after the rebuild, got %d", count)
+	}
+}
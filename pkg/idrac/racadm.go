@@ -0,0 +1,110 @@
+package idrac
+
+import (
+	"log"
+	"strings"
+)
+
+// vdiskProperties is the racadm property list requested for virtual disks
+const vdiskProperties = "layout,status,RemainingRedundancy,Size,Name,StripeSize,ReadPolicy,WritePolicy,OperationalState,OperationPercentComplete"
+
+// RacadmSource reads the storage inventory with the racadm tool, either from
+// the local iDRAC or from a remote one.
+type RacadmSource struct {
+	executor CommandExecutor
+	remote   []string
+}
+
+// NewRacadmSource returns a Source running racadm through executor.
+func NewRacadmSource(executor CommandExecutor) *RacadmSource {
+	return &RacadmSource{executor: executor}
+}
+
+// NewRemoteRacadmSource returns a Source running remote racadm through executor
+// against the iDRAC at host, i.e. "racadm -r host -u username -p password raid get ...".
+// racadm has no other way to take the password, so it is visible in the
+// process list of the host while racadm runs; RedfishSource does not expose it.
+func NewRemoteRacadmSource(executor CommandExecutor, host, username, password string) *RacadmSource {
+	return &RacadmSource{
+		executor: executor,
+		remote:   []string{"-r", host, "-u", username, "-p", password},
+	}
+}
+
+// racadm runs racadm with args, targeting the remote iDRAC if any.
+func (r *RacadmSource) racadm(args ...string) ([]byte, error) {
+	return r.executor.ExecuteCommand("racadm", append(append([]string{}, r.remote...), args...)...)
+}
+
+// GetRAIDStatus returns the racadm properties of every virtual disk keyed by
+// its FQDD, e.g. "Disk.Virtual.0:RAID.Integrated.1-1".
+func (r *RacadmSource) GetRAIDStatus() (map[string]map[string]string, error) {
+	log.Println("Executing racadm command to get RAID status...")
+	output, err := r.racadm("raid", "get", "vdisks", "-o", "-p", vdiskProperties)
+	if err != nil {
+		log.Printf("Error executing racadm command: %v", err)
+		return nil, err
+	}
+
+	log.Println("Parsing racadm command output...")
+	raidStatuses := parseRacadmObjects(string(output), fqddWithPrefix("Disk.Virtual"))
+	log.Println("Finished parsing racadm command output.")
+	return raidStatuses, nil
+}
+
+// getRacadmObjects runs "racadm raid get <object> -o" and parses its output with parseRacadmObjects.
+func (r *RacadmSource) getRacadmObjects(object string, key func(fqdd string) string) (map[string]map[string]string, error) {
+	log.Printf("Executing racadm command to get %s...", object)
+	output, err := r.racadm("raid", "get", object, "-o")
+	if err != nil {
+		log.Printf("Error executing racadm command: %v", err)
+		return nil, err
+	}
+
+	return parseRacadmObjects(string(output), key), nil
+}
+
+// fqddWithPrefix returns a parseRacadmObjects key function that keeps objects
+// whose FQDD starts with prefix, keyed by their full FQDD.
+func fqddWithPrefix(prefix string) func(fqdd string) string {
+	return func(fqdd string) string {
+		if !strings.HasPrefix(fqdd, prefix) {
+			return ""
+		}
+		return fqdd
+	}
+}
+
+// parseRacadmObjects parses the block format printed by "racadm raid get <object> -o":
+// an unindented line with the object FQDD followed by indented "Key = Value"
+// properties. Objects are keyed by key(fqdd), which returns "" to skip an
+// object, and the full FQDD is stored under "FQDD".
+func parseRacadmObjects(output string, key func(fqdd string) string) map[string]map[string]string {
+	objects := make(map[string]map[string]string)
+	var current string
+
+	for _, line := range strings.Split(output, "\n") {
+		if isRacadmHeader(line) {
+			fqdd := strings.TrimSpace(line)
+			current = key(fqdd)
+			if current != "" {
+				objects[current] = map[string]string{"FQDD": fqdd}
+			}
+		} else if current != "" && strings.Contains(line, "=") {
+			parts := strings.SplitN(line, "=", 2)
+			objects[current][strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	return objects
+}
+
+// isRacadmHeader reports whether line starts a new object block. FQDDs are
+// unindented and never contain whitespace or "=".
+func isRacadmHeader(line string) bool {
+	line = strings.TrimRight(line, "\r")
+	if line == "" || line[0] == ' ' || line[0] == '\t' {
+		return false
+	}
+	return !strings.ContainsAny(line, " \t=")
+}
@@ -0,0 +1,535 @@
+package idrac
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedfishConfig configures access to an iDRAC over Redfish.
+type RedfishConfig struct {
+	// Endpoint is the base URL of the iDRAC, e.g. https://idrac.example.com.
+	Endpoint string
+	Username string
+	Password string
+	// SystemID is the Redfish system to query, System.Embedded.1 by default.
+	SystemID string
+	// InsecureSkipVerify disables TLS certificate verification. iDRACs ship
+	// with self-signed certificates.
+	InsecureSkipVerify bool
+	// Timeout bounds every HTTP request, 60 seconds by default.
+	Timeout time.Duration
+}
+
+// RedfishSource reads the storage inventory from the iDRAC Redfish API
+// (Systems/Storage, Volumes, Drives and Chassis), so no local tooling is needed.
+// Resources are translated into the racadm property names used by RacadmSource.
+type RedfishSource struct {
+	config RedfishConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	snapshot *redfishInventory
+}
+
+// redfishInventory holds the storage resources and their volumes, read once
+// per update by Refresh.
+type redfishInventory struct {
+	storages []redfishStorage
+	// volumes holds the volumes of each storage resource by storage ID.
+	volumes map[string][]redfishVolume
+}
+
+// NewRedfishSource returns a Source querying the iDRAC described by config.
+func NewRedfishSource(config RedfishConfig) *RedfishSource {
+	if config.SystemID == "" {
+		config.SystemID = "System.Embedded.1"
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 60 * time.Second
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	return &RedfishSource{
+		config: config,
+		client: &http.Client{Transport: transport, Timeout: config.Timeout},
+	}
+}
+
+type redfishLink struct {
+	ID string `json:"@odata.id"`
+}
+
+type redfishCollection struct {
+	Members []redfishLink `json:"Members"`
+}
+
+type redfishStatus struct {
+	Health string `json:"Health"`
+	State  string `json:"State"`
+}
+
+type redfishOperation struct {
+	OperationName      string   `json:"OperationName"`
+	PercentageComplete *float64 `json:"PercentageComplete"`
+}
+
+type redfishStorage struct {
+	ID                 string        `json:"Id"`
+	Name               string        `json:"Name"`
+	Status             redfishStatus `json:"Status"`
+	Drives             []redfishLink `json:"Drives"`
+	Volumes            redfishLink   `json:"Volumes"`
+	StorageControllers []struct {
+		Name            string `json:"Name"`
+		FirmwareVersion string `json:"FirmwareVersion"`
+		CacheSummary    struct {
+			TotalCacheSizeMiB *float64 `json:"TotalCacheSizeMiB"`
+		} `json:"CacheSummary"`
+	} `json:"StorageControllers"`
+	Links struct {
+		Enclosures []redfishLink `json:"Enclosures"`
+	} `json:"Links"`
+	Oem struct {
+		Dell struct {
+			DellController struct {
+				DriverVersion string `json:"DriverVersion"`
+			} `json:"DellController"`
+			DellControllerBattery *struct {
+				ID            string `json:"Id"`
+				Name          string `json:"Name"`
+				PrimaryStatus string `json:"PrimaryStatus"`
+				RAIDState     string `json:"RAIDState"`
+			} `json:"DellControllerBattery"`
+		} `json:"Dell"`
+	} `json:"Oem"`
+}
+
+type redfishVolume struct {
+	ID                 string             `json:"Id"`
+	Name               string             `json:"Name"`
+	RAIDType           string             `json:"RAIDType"`
+	VolumeType         string             `json:"VolumeType"`
+	CapacityBytes      *float64           `json:"CapacityBytes"`
+	OptimumIOSizeBytes *float64           `json:"OptimumIOSizeBytes"`
+	ReadCachePolicy    string             `json:"ReadCachePolicy"`
+	WriteCachePolicy   string             `json:"WriteCachePolicy"`
+	Status             redfishStatus      `json:"Status"`
+	Operations         []redfishOperation `json:"Operations"`
+	Links              struct {
+		Drives []redfishLink `json:"Drives"`
+	} `json:"Links"`
+}
+
+type redfishDrive struct {
+	ID                            string             `json:"Id"`
+	Name                          string             `json:"Name"`
+	CapacityBytes                 *float64           `json:"CapacityBytes"`
+	MediaType                     string             `json:"MediaType"`
+	Protocol                      string             `json:"Protocol"`
+	Manufacturer                  string             `json:"Manufacturer"`
+	Model                         string             `json:"Model"`
+	SerialNumber                  string             `json:"SerialNumber"`
+	Revision                      string             `json:"Revision"`
+	FailurePredicted              bool               `json:"FailurePredicted"`
+	HotspareType                  string             `json:"HotspareType"`
+	PredictedMediaLifeLeftPercent *float64           `json:"PredictedMediaLifeLeftPercent"`
+	Status                        redfishStatus      `json:"Status"`
+	Operations                    []redfishOperation `json:"Operations"`
+	Oem                           struct {
+		Dell struct {
+			DellPhysicalDisk struct {
+				RaidStatus string `json:"RaidStatus"`
+			} `json:"DellPhysicalDisk"`
+		} `json:"Dell"`
+	} `json:"Oem"`
+}
+
+type redfishChassis struct {
+	ID     string        `json:"Id"`
+	Name   string        `json:"Name"`
+	Status redfishStatus `json:"Status"`
+	Oem    struct {
+		Dell struct {
+			DellEnclosure struct {
+				SlotCount *int   `json:"SlotCount"`
+				Connector *int   `json:"Connector"`
+				Version   string `json:"Version"`
+			} `json:"DellEnclosure"`
+		} `json:"Dell"`
+	} `json:"Oem"`
+}
+
+// get fetches the Redfish resource at resource (e.g. "/redfish/v1/Systems") into v.
+func (r *RedfishSource) get(resource string, v interface{}) error {
+	url := strings.TrimRight(r.config.Endpoint, "/") + resource
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(r.config.Username, r.config.Password)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", resource, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("GET %s: %w", resource, err)
+	}
+	return nil
+}
+
+// Refresh implements Refresher. It reads the storage resources and their
+// volumes, served by the Get methods until the next Refresh.
+func (r *RedfishSource) Refresh() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.snapshot = nil
+	inventory, err := r.readInventory()
+	if err != nil {
+		return err
+	}
+	r.snapshot = inventory
+	return nil
+}
+
+// inventory returns the inventory read by the last Refresh, or reads it when
+// the source was never refreshed.
+func (r *RedfishSource) inventory() (*redfishInventory, error) {
+	r.mu.Lock()
+	snapshot := r.snapshot
+	r.mu.Unlock()
+	if snapshot != nil {
+		return snapshot, nil
+	}
+	return r.readInventory()
+}
+
+func (r *RedfishSource) readInventory() (*redfishInventory, error) {
+	storages, err := r.storages()
+	if err != nil {
+		return nil, err
+	}
+
+	inventory := &redfishInventory{storages: storages, volumes: make(map[string][]redfishVolume)}
+	for _, storage := range storages {
+		volumes, err := r.volumes(storage)
+		if err != nil {
+			return nil, err
+		}
+		inventory.volumes[storage.ID] = volumes
+	}
+	return inventory, nil
+}
+
+func (r *RedfishSource) storages() ([]redfishStorage, error) {
+	var collection redfishCollection
+	if err := r.get("/redfish/v1/Systems/"+r.config.SystemID+"/Storage", &collection); err != nil {
+		return nil, err
+	}
+
+	storages := make([]redfishStorage, 0, len(collection.Members))
+	for _, member := range collection.Members {
+		var storage redfishStorage
+		if err := r.get(member.ID, &storage); err != nil {
+			return nil, err
+		}
+		storages = append(storages, storage)
+	}
+	return storages, nil
+}
+
+func (r *RedfishSource) volumes(storage redfishStorage) ([]redfishVolume, error) {
+	if storage.Volumes.ID == "" {
+		return nil, nil
+	}
+	var collection redfishCollection
+	if err := r.get(storage.Volumes.ID, &collection); err != nil {
+		return nil, err
+	}
+
+	volumes := make([]redfishVolume, 0, len(collection.Members))
+	for _, member := range collection.Members {
+		var volume redfishVolume
+		if err := r.get(member.ID, &volume); err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes, nil
+}
+
+// GetRAIDStatus returns the properties of every volume keyed by its FQDD, like
+// RacadmSource.GetRAIDStatus.
+func (r *RedfishSource) GetRAIDStatus() (map[string]map[string]string, error) {
+	log.Println("Querying Redfish to get RAID status...")
+	inventory, err := r.inventory()
+	if err != nil {
+		return nil, err
+	}
+
+	raidStatuses := make(map[string]map[string]string)
+	for _, storage := range inventory.storages {
+		for _, volume := range inventory.volumes[storage.ID] {
+			if volume.ID == "" {
+				continue
+			}
+			properties := map[string]string{
+				"FQDD":        volume.ID,
+				"Status":      redfishHealth(volume.Status),
+				"Layout":      redfishLayout(volume),
+				"Name":        volume.Name,
+				"ReadPolicy":  volume.ReadCachePolicy,
+				"WritePolicy": volume.WriteCachePolicy,
+			}
+			if volume.CapacityBytes != nil {
+				properties["Size"] = formatBytes(*volume.CapacityBytes)
+			}
+			if volume.OptimumIOSizeBytes != nil {
+				properties["StripeSize"] = fmt.Sprintf("%.0fK", *volume.OptimumIOSizeBytes/1024)
+			}
+			setRedfishOperation(properties, volume.Operations)
+			if name, ok := properties["OperationName"]; ok {
+				properties["OperationalState"] = name
+			}
+			raidStatuses[volume.ID] = properties
+		}
+	}
+	return raidStatuses, nil
+}
+
+// GetVDiskMembers returns the FQDDs of the drives linked to the volume vdiskFQDD.
+func (r *RedfishSource) GetVDiskMembers(vdiskFQDD string) ([]string, error) {
+	inventory, err := r.inventory()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, volume := range inventory.volumes[controllerFromFQDD(vdiskFQDD)] {
+		if volume.ID != vdiskFQDD {
+			continue
+		}
+		members := make([]string, 0, len(volume.Links.Drives))
+		for _, drive := range volume.Links.Drives {
+			members = append(members, path.Base(drive.ID))
+		}
+		return members, nil
+	}
+	return nil, fmt.Errorf("volume %s not found", vdiskFQDD)
+}
+
+// GetPhysicalDisks returns the properties of every drive keyed by its FQDD.
+func (r *RedfishSource) GetPhysicalDisks() (map[string]map[string]string, error) {
+	log.Println("Querying Redfish to get physical disks...")
+	inventory, err := r.inventory()
+	if err != nil {
+		return nil, err
+	}
+
+	pdisks := make(map[string]map[string]string)
+	for _, storage := range inventory.storages {
+		for _, link := range storage.Drives {
+			var drive redfishDrive
+			if err := r.get(link.ID, &drive); err != nil {
+				return nil, err
+			}
+			state := drive.Oem.Dell.DellPhysicalDisk.RaidStatus
+			if state == "" {
+				state = drive.Status.State
+			}
+			properties := map[string]string{
+				"FQDD":             drive.ID,
+				"Status":           redfishHealth(drive.Status),
+				"State":            state,
+				"Name":             drive.Name,
+				"MediaType":        drive.MediaType,
+				"BusProtocol":      drive.Protocol,
+				"Manufacturer":     drive.Manufacturer,
+				"ProductId":        drive.Model,
+				"SerialNumber":     drive.SerialNumber,
+				"Revision":         drive.Revision,
+				"FailurePredicted": "NO",
+				"Hotspare":         "NO",
+			}
+			if drive.FailurePredicted {
+				properties["FailurePredicted"] = "YES"
+			}
+			if drive.HotspareType != "" && drive.HotspareType != "None" {
+				properties["Hotspare"] = drive.HotspareType
+			}
+			if drive.CapacityBytes != nil {
+				properties["Size"] = formatBytes(*drive.CapacityBytes)
+			}
+			if drive.PredictedMediaLifeLeftPercent != nil {
+				properties["RemainingRatedWriteEndurance"] = fmt.Sprintf("%.0f %%", *drive.PredictedMediaLifeLeftPercent)
+			}
+			setRedfishOperation(properties, drive.Operations)
+			pdisks[drive.ID] = properties
+		}
+	}
+	return pdisks, nil
+}
+
+// GetControllers returns the properties of every storage controller keyed by its FQDD.
+func (r *RedfishSource) GetControllers() (map[string]map[string]string, error) {
+	inventory, err := r.inventory()
+	if err != nil {
+		return nil, err
+	}
+
+	controllers := make(map[string]map[string]string)
+	for _, storage := range inventory.storages {
+		properties := map[string]string{
+			"FQDD":          storage.ID,
+			"Status":        redfishHealth(storage.Status),
+			"Name":          storage.Name,
+			"DriverVersion": storage.Oem.Dell.DellController.DriverVersion,
+		}
+		if len(storage.StorageControllers) > 0 {
+			controller := storage.StorageControllers[0]
+			if controller.Name != "" {
+				properties["Name"] = controller.Name
+			}
+			properties["FirmwareVersion"] = controller.FirmwareVersion
+			if controller.CacheSummary.TotalCacheSizeMiB != nil {
+				properties["CacheMemorySize"] = fmt.Sprintf("%.0f MiB", *controller.CacheSummary.TotalCacheSizeMiB)
+			}
+		}
+		controllers[storage.ID] = properties
+	}
+	return controllers, nil
+}
+
+// GetBatteries returns the properties of every controller battery keyed by its FQDD,
+// read from the Dell OEM extension of the storage resource.
+func (r *RedfishSource) GetBatteries() (map[string]map[string]string, error) {
+	inventory, err := r.inventory()
+	if err != nil {
+		return nil, err
+	}
+
+	batteries := make(map[string]map[string]string)
+	for _, storage := range inventory.storages {
+		battery := storage.Oem.Dell.DellControllerBattery
+		if battery == nil || battery.ID == "" {
+			continue
+		}
+		batteries[battery.ID] = map[string]string{
+			"FQDD":   battery.ID,
+			"Name":   battery.Name,
+			"Status": redfishHealth(redfishStatus{Health: battery.PrimaryStatus}),
+			"State":  battery.RAIDState,
+		}
+	}
+	return batteries, nil
+}
+
+// GetEnclosures returns the properties of every enclosure linked to a storage resource.
+func (r *RedfishSource) GetEnclosures() (map[string]map[string]string, error) {
+	inventory, err := r.inventory()
+	if err != nil {
+		return nil, err
+	}
+
+	enclosures := make(map[string]map[string]string)
+	for _, storage := range inventory.storages {
+		for _, link := range storage.Links.Enclosures {
+			// Storage resources also link the server chassis itself.
+			if !strings.HasPrefix(path.Base(link.ID), "Enclosure.") {
+				continue
+			}
+			var chassis redfishChassis
+			if err := r.get(link.ID, &chassis); err != nil {
+				return nil, err
+			}
+			dell := chassis.Oem.Dell.DellEnclosure
+			properties := map[string]string{
+				"FQDD":            chassis.ID,
+				"Status":          redfishHealth(chassis.Status),
+				"Name":            chassis.Name,
+				"FirmwareVersion": dell.Version,
+			}
+			if dell.SlotCount != nil {
+				properties["SlotCount"] = fmt.Sprint(*dell.SlotCount)
+			}
+			if dell.Connector != nil {
+				properties["Connector"] = fmt.Sprint(*dell.Connector)
+			}
+			enclosures[chassis.ID] = properties
+		}
+	}
+	return enclosures, nil
+}
+
+// GetEMMs is not available over Redfish and always returns no objects.
+func (r *RedfishSource) GetEMMs() (map[string]map[string]string, error) {
+	return map[string]map[string]string{}, nil
+}
+
+// GetTemperatureProbes is not available over Redfish and always returns no objects.
+func (r *RedfishSource) GetTemperatureProbes() (map[string]map[string]string, error) {
+	return map[string]map[string]string{}, nil
+}
+
+// redfishHealth converts a Redfish status into the racadm Status vocabulary.
+func redfishHealth(status redfishStatus) string {
+	switch strings.ToLower(status.State) {
+	case "absent", "unavailableoffline":
+		return "Offline"
+	}
+	switch strings.ToLower(status.Health) {
+	case "ok":
+		return "Ok"
+	case "warning":
+		return "Degraded"
+	case "critical":
+		return "Failed"
+	default:
+		return "Unknown"
+	}
+}
+
+// redfishVolumeTypes maps the deprecated Redfish VolumeType to a racadm layout.
+var redfishVolumeTypes = map[string]string{
+	"NonRedundant":             "Raid-0",
+	"Mirrored":                 "Raid-1",
+	"StripedWithParity":        "Raid-5",
+	"SpannedMirrors":           "Raid-10",
+	"SpannedStripesWithParity": "Raid-50",
+}
+
+// redfishLayout converts the Redfish RAIDType (e.g. "RAID10") into the racadm Layout ("Raid-10").
+func redfishLayout(volume redfishVolume) string {
+	if level := strings.TrimPrefix(strings.ToUpper(volume.RAIDType), "RAID"); level != "" && level != strings.ToUpper(volume.RAIDType) {
+		return "Raid-" + level
+	}
+	return redfishVolumeTypes[volume.VolumeType]
+}
+
+// setRedfishOperation copies the first running operation into properties.
+func setRedfishOperation(properties map[string]string, operations []redfishOperation) {
+	if len(operations) == 0 {
+		return
+	}
+	properties["OperationName"] = operations[0].OperationName
+	if operations[0].PercentageComplete != nil {
+		properties["OperationPercentComplete"] = fmt.Sprintf("%.0f %%", *operations[0].PercentageComplete)
+	}
+}
+
+func formatBytes(bytes float64) string {
+	return fmt.Sprintf("%.0f Bytes", bytes)
+}
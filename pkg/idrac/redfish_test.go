@@ -0,0 +1,213 @@
+package idrac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// redfishRequests counts the requests served by newRedfishServer by path.
+type redfishRequests struct {
+	mu    sync.Mutex
+	paths map[string]int
+}
+
+func (r *redfishRequests) count(path string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.paths[path]
+}
+
+// newRedfishServer serves the recorded Redfish responses in testdata/redfish.
+func newRedfishServer(t *testing.T) *httptest.Server {
+	server, _ := newCountingRedfishServer(t)
+	return server
+}
+
+// newCountingRedfishServer is newRedfishServer also counting the requests.
+func newCountingRedfishServer(t *testing.T) (*httptest.Server, *redfishRequests) {
+	t.Helper()
+	const storage = "/redfish/v1/Systems/System.Embedded.1/Storage"
+	const controller = storage + "/RAID.Integrated.1-1"
+	files := map[string]string{
+		storage:                 "storage.json",
+		controller:              "controller.json",
+		controller + "/Volumes": "volumes.json",
+		controller + "/Volumes/Disk.Virtual.0:RAID.Integrated.1-1":                   "volume.json",
+		controller + "/Volumes/Disk.Virtual.1:RAID.Integrated.1-1":                   "volume1.json",
+		controller + "/Drives/Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1": "drive0.json",
+		controller + "/Drives/Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1": "drive1.json",
+		"/redfish/v1/Chassis/Enclosure.Internal.0-1:RAID.Integrated.1-1":             "enclosure.json",
+	}
+
+	requests := &redfishRequests{paths: make(map[string]int)}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.mu.Lock()
+		requests.paths[r.URL.Path]++
+		requests.mu.Unlock()
+		if username, password, ok := r.BasicAuth(); !ok || username != "root" || password != "calvin" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		name, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := os.ReadFile("testdata/redfish/" + name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func newTestRedfishSource(server *httptest.Server) *RedfishSource {
+	return NewRedfishSource(RedfishConfig{
+		Endpoint:           server.URL,
+		Username:           "root",
+		Password:           "calvin",
+		InsecureSkipVerify: true,
+	})
+}
+
+func TestRedfishGetRAIDStatus(t *testing.T) {
+	source := newTestRedfishSource(newRedfishServer(t))
+
+	statuses, err := source.GetRAIDStatus()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := map[string]map[string]string{
+		"Disk.Virtual.0:RAID.Integrated.1-1": {
+			"FQDD":                     "Disk.Virtual.0:RAID.Integrated.1-1",
+			"Status":                   "Degraded",
+			"Layout":                   "Raid-1",
+			"Name":                     "OS",
+			"Size":                     "128849018880 Bytes",
+			"StripeSize":               "64K",
+			"ReadPolicy":               "ReadAhead",
+			"WritePolicy":              "WriteBack",
+			"OperationName":            "Rebuilding",
+			"OperationalState":         "Rebuilding",
+			"OperationPercentComplete": "37 %",
+		},
+		"Disk.Virtual.1:RAID.Integrated.1-1": {
+			"FQDD":                     "Disk.Virtual.1:RAID.Integrated.1-1",
+			"Status":                   "Degraded",
+			"Layout":                   "Raid-1",
+			"Name":                     "DATA",
+			"Size":                     "350710923264 Bytes",
+			"StripeSize":               "64K",
+			"ReadPolicy":               "ReadAhead",
+			"WritePolicy":              "WriteBack",
+			"OperationName":            "Rebuilding",
+			"OperationalState":         "Rebuilding",
+			"OperationPercentComplete": "37 %",
+		},
+	}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Fatalf("Expected %v, got %v", expected, statuses)
+	}
+}
+
+func TestRedfishUnauthorized(t *testing.T) {
+	server := newRedfishServer(t)
+	source := NewRedfishSource(RedfishConfig{
+		Endpoint:           server.URL,
+		Username:           "root",
+		Password:           "wrong",
+		InsecureSkipVerify: true,
+	})
+
+	if _, err := source.GetRAIDStatus(); err == nil {
+		t.Fatal("Expected an error for invalid credentials")
+	}
+}
+
+func TestRedfishLayout(t *testing.T) {
+	tests := []struct {
+		volume   redfishVolume
+		expected string
+	}{
+		{redfishVolume{RAIDType: "RAID10"}, "Raid-10"},
+		{redfishVolume{RAIDType: "RAID0", VolumeType: "NonRedundant"}, "Raid-0"},
+		{redfishVolume{VolumeType: "StripedWithParity"}, "Raid-5"},
+		{redfishVolume{VolumeType: "RawDevice"}, ""},
+	}
+
+	for _, test := range tests {
+		if layout := redfishLayout(test.volume); layout != test.expected {
+			t.Errorf("redfishLayout(%+v) = %q, expected %q", test.volume, layout, test.expected)
+		}
+	}
+}
+
+func TestUpdateMetricsRedfish(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	NewClientWithSource(newTestRedfishSource(newRedfishServer(t)), registry, 5*time.Minute)
+
+	expected := `
+# HELP raid_battery_status Status of the RAID controller battery (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)
+# TYPE raid_battery_status gauge
+raid_battery_status{battery="Battery.Integrated.1:RAID.Integrated.1-1"} 1
+# HELP raid_controller_cache_size_bytes Size of the RAID controller cache memory in bytes
+# TYPE raid_controller_cache_size_bytes gauge
+raid_controller_cache_size_bytes{controller="RAID.Integrated.1-1"} 2.147483648e+09
+# HELP raid_enclosure_slots Number of drive slots in the enclosure or backplane
+# TYPE raid_enclosure_slots gauge
+raid_enclosure_slots{enclosure="Enclosure.Internal.0-1:RAID.Integrated.1-1"} 8
+# HELP raid_pdisk_rebuild_progress_ratio Progress of the rebuild of the physical disk, from 0 to 1
+# TYPE raid_pdisk_rebuild_progress_ratio gauge
+raid_pdisk_rebuild_progress_ratio{pdisk="Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1"} 0.37
+# HELP raid_pdisk_vdisk Membership of the physical disk in a RAID virtual disk, always 1
+# TYPE raid_pdisk_vdisk gauge
+raid_pdisk_vdisk{pdisk="Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+raid_pdisk_vdisk{pdisk="Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1
+raid_pdisk_vdisk{pdisk="Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 1
+raid_pdisk_vdisk{pdisk="Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1",vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 1
+# HELP raid_status Status of the RAID virtual disk (0=Unknown, 1=Ok, 2=Rebuilding, 3=Degraded, 4=Offline, 5=Failed)
+# TYPE raid_status gauge
+raid_status{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 3
+raid_status{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.1:RAID.Integrated.1-1"} 3
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"raid_battery_status", "raid_controller_cache_size_bytes", "raid_enclosure_slots",
+		"raid_pdisk_rebuild_progress_ratio", "raid_pdisk_vdisk", "raid_status"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestUpdateRedfishRequestsOnce(t *testing.T) {
+	server, requests := newCountingRedfishServer(t)
+	client := NewClientWithSource(newTestRedfishSource(server), prometheus.NewRegistry(), 5*time.Minute)
+
+	for i := 1; i <= 2; i++ {
+		if err := client.Update(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		// The storage resources and volumes are read once per update
+		for _, resource := range []string{
+			"/redfish/v1/Systems/System.Embedded.1/Storage",
+			"/redfish/v1/Systems/System.Embedded.1/Storage/RAID.Integrated.1-1",
+			"/redfish/v1/Systems/System.Embedded.1/Storage/RAID.Integrated.1-1/Volumes/Disk.Virtual.0:RAID.Integrated.1-1",
+		} {
+			if count := requests.count(resource); count != i {
+				t.Fatalf("Expected %d requests for %s after %d updates, got %d", i, resource, i, count)
+			}
+		}
+	}
+}
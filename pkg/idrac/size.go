@@ -0,0 +1,95 @@
+package idrac
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SizeBase selects how SI-style unit suffixes (KB, MB, GB, ...) are interpreted.
+type SizeBase int
+
+const (
+	// BinarySizeBase treats GB as 1024^3 bytes. This is what racadm reports.
+	BinarySizeBase SizeBase = iota
+	// decimalSizeBase treats GB as 1000^3 bytes.
+	decimalSizeBase
+)
+
+// sizeExponents maps unit suffixes to their power of the base.
+var sizeExponents = map[string]int{
+	"b":     0,
+	"byte":  0,
+	"bytes": 0,
+	"kb":    1,
+	"mb":    2,
+	"gb":    3,
+	"tb":    4,
+	"pb":    5,
+}
+
+// binarySizeExponents maps IEC unit suffixes, which are always binary.
+var binarySizeExponents = map[string]int{
+	"kib": 1,
+	"mib": 2,
+	"gib": 3,
+	"tib": 4,
+	"pib": 5,
+}
+
+// exactBytesPattern matches the "(300000000000 bytes)" suffix some racadm versions append.
+var exactBytesPattern = regexp.MustCompile(`(?i)\(\s*([0-9][0-9,]*)\s*bytes?\s*\)`)
+
+// ParseSize converts a racadm size such as "1787.50 GB" into bytes.
+func ParseSize(value string, base SizeBase) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	if match := exactBytesPattern.FindStringSubmatch(value); match != nil {
+		return strconv.ParseFloat(strings.ReplaceAll(match[1], ",", ""), 64)
+	}
+
+	number, unit := splitNumberUnit(value)
+	parsed, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", value, err)
+	}
+	if unit == "" {
+		return parsed, nil
+	}
+
+	unit = strings.ToLower(unit)
+	multiplier := 1024.0
+	exponent, ok := binarySizeExponents[unit]
+	if !ok {
+		exponent, ok = sizeExponents[unit]
+		if !ok {
+			return 0, fmt.Errorf("unknown size unit %q in %q", unit, value)
+		}
+		if base == decimalSizeBase {
+			multiplier = 1000
+		}
+	}
+
+	for i := 0; i < exponent; i++ {
+		parsed *= multiplier
+	}
+	return parsed, nil
+}
+
+// splitNumberUnit splits "1787.50 GB" or "1787.50GB" into its number and unit.
+func splitNumberUnit(value string) (string, string) {
+	if fields := strings.Fields(value); len(fields) >= 2 {
+		return fields[0], fields[1]
+	}
+	i := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != '-' && r != '+'
+	})
+	if i < 0 {
+		return value, ""
+	}
+	return value[:i], value[i:]
+}
@@ -0,0 +1,87 @@
+package idrac
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		base     SizeBase
+		expected float64
+	}{
+		{"1787.50 GB", BinarySizeBase, 1787.5 * 1024 * 1024 * 1024},
+		{"372.00 GB", BinarySizeBase, 372 * 1024 * 1024 * 1024},
+		{"1.5 TB", BinarySizeBase, 1.5 * 1024 * 1024 * 1024 * 1024},
+		{"512 MB", BinarySizeBase, 512 * 1024 * 1024},
+		{"64 KB", BinarySizeBase, 64 * 1024},
+		{"2 PB", BinarySizeBase, 2 * 1024 * 1024 * 1024 * 1024 * 1024},
+		{"1.5 TB", decimalSizeBase, 1.5e12},
+		{"372.00 GB", decimalSizeBase, 372e9},
+		{"1 GiB", decimalSizeBase, 1024 * 1024 * 1024},
+		{"10TiB", BinarySizeBase, 10 * 1024 * 1024 * 1024 * 1024},
+		{"256K", BinarySizeBase, 0},
+		{"4096 Bytes", BinarySizeBase, 4096},
+		{"4096", BinarySizeBase, 4096},
+		{"278.88 GB (299439751168 bytes)", decimalSizeBase, 299439751168},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSize(tt.input, tt.base)
+		if tt.expected == 0 {
+			if err == nil {
+				t.Errorf("ParseSize(%q) expected error, got %v", tt.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSize(%q) unexpected error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ParseSize(%q) = %v, expected %v", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestParseSizeInvalid(t *testing.T) {
+	for _, input := range []string{"", "   ", "GB", "abc GB", "12 XB"} {
+		if _, err := ParseSize(input, BinarySizeBase); err == nil {
+			t.Errorf("ParseSize(%q) expected error, got none", input)
+		}
+	}
+}
+
+func TestUpdateMetricsParseErrors(t *testing.T) {
+	mockExecutor := &MockCommandExecutor{
+		MockOutput: `
+Disk.Virtual.0:RAID.Integrated.1-1
+   Layout                           = Raid-1
+   Status                           = Ok
+   RemainingRedundancy              =
+   Size                             = unknown
+`,
+	}
+
+	registry := prometheus.NewRegistry()
+	client := NewClient(mockExecutor, registry, 5*time.Minute)
+
+	expectedErrors := `
+# HELP raid_parse_errors_total Number of racadm property values that could not be parsed
+# TYPE raid_parse_errors_total counter
+raid_parse_errors_total{object="Disk.Virtual.0:RAID.Integrated.1-1",property="RemainingRedundancy"} 1
+raid_parse_errors_total{object="Disk.Virtual.0:RAID.Integrated.1-1",property="Size"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expectedErrors), "raid_parse_errors_total"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+
+	if count := testutil.CollectAndCount(client.vdiskSizeBytes); count != 0 {
+		t.Fatalf("Expected no size series for unparseable size, got %d", count)
+	}
+}
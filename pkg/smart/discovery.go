@@ -0,0 +1,205 @@
+package smart
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultSysfsRoot is the mount point of sysfs walked by the NVMe discovery
+// unless another one is set on Metrics or SelfTestScheduler.
+const DefaultSysfsRoot = "/sys"
+
+var (
+	controllerName = regexp.MustCompile(`^nvme\d+$`)
+	// namespaceName matches namespace block devices such as nvme0n1. With
+	// native multipath the controller holds hidden per-path devices such as
+	// nvme1c2n1 instead, whose head is nvme1n1.
+	namespaceName     = regexp.MustCompile(`^nvme\d+n\d+$`)
+	namespacePathName = regexp.MustCompile(`^(nvme\d+)c\d+(n\d+)$`)
+	namespaceInstance = regexp.MustCompile(`^(nvme\d+)n\d+$`)
+)
+
+// namespaceController returns the controller of the namespace block device
+// name, e.g. "nvme0" for "nvme0n1". With native multipath the instance is the
+// one of the subsystem, so every namespace of the subsystem shares it.
+func namespaceController(name string) string {
+	if match := namespaceInstance.FindStringSubmatch(name); match != nil {
+		return match[1]
+	}
+	return name
+}
+
+// Controller is an NVMe controller listed in /sys/class/nvme.
+type Controller struct {
+	// Name is the controller character device, e.g. "nvme0".
+	Name     string
+	Model    string
+	Serial   string
+	Firmware string
+	// Transport is "pcie", "tcp", "rdma", "fc" or "loop".
+	Transport string
+	// Address is the PCI address for PCIe controllers, e.g. "0000:3d:00.0".
+	Address string
+	// VendorID is the PCI vendor ID, e.g. "0x144d", empty for fabrics.
+	VendorID     string
+	Subsystem    string
+	SubsystemNQN string
+	// Namespaces are the block devices reachable through the controller.
+	Namespaces []string
+}
+
+var controllerInfoDesc = prometheus.NewDesc(
+	"nvme_controller_info",
+	"Attributes of the NVMe controller read from sysfs, always 1",
+	[]string{"controller", "subsystem", "transport", "address", "model", "serial", "firmware"}, nil,
+)
+
+// collectControllerInfo sends nvme_controller_info for controller.
+func collectControllerInfo(ch chan<- prometheus.Metric, controller Controller) {
+	ch <- prometheus.MustNewConstMetric(controllerInfoDesc, prometheus.GaugeValue, 1,
+		controller.Name, controller.Subsystem, controller.Transport, controller.Address,
+		controller.Model, controller.Serial, controller.Firmware)
+}
+
+// Namespace is an NVMe namespace block device listed in /sys/block.
+type Namespace struct {
+	// Name is the block device, e.g. "nvme0n1".
+	Name      string
+	Subsystem string
+	// Controllers are the controllers the namespace is reachable through,
+	// more than one for multipath devices.
+	Controllers []string
+}
+
+// Topology is the result of the NVMe discovery.
+type Topology struct {
+	Controllers []Controller
+	Namespaces  []Namespace
+}
+
+// Discover enumerates the NVMe controllers, namespaces and subsystems from the
+// sysfs tree mounted at root. A host without NVMe devices yields an empty Topology.
+func Discover(root string) (*Topology, error) {
+	subsystems, err := controllerSubsystems(root)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := readDirNames(filepath.Join(root, "class", "nvme"))
+	if err != nil {
+		return nil, err
+	}
+	topology := &Topology{}
+	for _, name := range entries {
+		if !controllerName.MatchString(name) {
+			continue
+		}
+		controller, err := readController(root, name)
+		if err != nil {
+			return nil, err
+		}
+		controller.Subsystem = subsystems[name]
+		topology.Controllers = append(topology.Controllers, controller)
+	}
+
+	blocks, err := readDirNames(filepath.Join(root, "block"))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range blocks {
+		if !namespaceName.MatchString(name) {
+			continue
+		}
+		namespace := Namespace{Name: name}
+		for _, controller := range topology.Controllers {
+			for _, ns := range controller.Namespaces {
+				if ns == name {
+					namespace.Controllers = append(namespace.Controllers, controller.Name)
+					namespace.Subsystem = controller.Subsystem
+				}
+			}
+		}
+		topology.Namespaces = append(topology.Namespaces, namespace)
+	}
+	return topology, nil
+}
+
+// controllerSubsystems maps every controller to the subsystem listing it in
+// /sys/class/nvme-subsystem, e.g. "nvme0" to "nvme-subsys0".
+func controllerSubsystems(root string) (map[string]string, error) {
+	subsystems := make(map[string]string)
+	names, err := readDirNames(filepath.Join(root, "class", "nvme-subsystem"))
+	if err != nil {
+		return nil, err
+	}
+	for _, subsystem := range names {
+		entries, err := readDirNames(filepath.Join(root, "class", "nvme-subsystem", subsystem))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if controllerName.MatchString(entry) {
+				subsystems[entry] = subsystem
+			}
+		}
+	}
+	return subsystems, nil
+}
+
+func readController(root, name string) (Controller, error) {
+	dir := filepath.Join(root, "class", "nvme", name)
+	controller := Controller{
+		Name:         name,
+		Model:        readAttribute(dir, "model"),
+		Serial:       readAttribute(dir, "serial"),
+		Firmware:     readAttribute(dir, "firmware_rev"),
+		Transport:    readAttribute(dir, "transport"),
+		Address:      readAttribute(dir, "address"),
+		VendorID:     readAttribute(dir, "device/vendor"),
+		SubsystemNQN: readAttribute(dir, "subsysnqn"),
+	}
+
+	entries, err := readDirNames(dir)
+	if err != nil {
+		return controller, err
+	}
+	for _, entry := range entries {
+		if namespaceName.MatchString(entry) {
+			controller.Namespaces = append(controller.Namespaces, entry)
+		} else if match := namespacePathName.FindStringSubmatch(entry); match != nil {
+			controller.Namespaces = append(controller.Namespaces, match[1]+match[2])
+		}
+	}
+	return controller, nil
+}
+
+// readAttribute returns the trimmed content of a sysfs attribute, or "" if it
+// does not exist for this device.
+func readAttribute(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readDirNames returns the entry names of dir sorted by name, or none if it does not exist.
+func readDirNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
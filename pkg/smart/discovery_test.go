@@ -0,0 +1,125 @@
+package smart
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDiscover(t *testing.T) {
+	topology, err := Discover("testdata/sysfs")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expectedControllers := []Controller{
+		{
+			Name:         "nvme0",
+			Model:        "Dell Ent NVMe v2 AGN RI U.2 1.92TB",
+			Serial:       "S6CRNA0R500123",
+			Firmware:     "2.1.8",
+			Transport:    "pcie",
+			Address:      "0000:3d:00.0",
+			VendorID:     "0x144d",
+			Subsystem:    "nvme-subsys0",
+			SubsystemNQN: "nqn.1994-11.com.samsung:nvme:PM1733:2.5-inch:S6CRNA0R500123",
+			Namespaces:   []string{"nvme0n1"},
+		},
+		{
+			Name:         "nvme1",
+			Model:        "Dell Ent NVMe CM6 MU 3.2TB",
+			Serial:       "Y0R0A03ETC98",
+			Firmware:     "2.1.3",
+			Transport:    "pcie",
+			Address:      "0000:5e:00.0",
+			VendorID:     "0x1e0f",
+			Subsystem:    "nvme-subsys1",
+			SubsystemNQN: "nqn.2019-10.com.kioxia:KCM6XVUL3T20:Y0R0A03ETC98",
+			Namespaces:   []string{"nvme1n1"},
+		},
+		{
+			Name:         "nvme2",
+			Model:        "Dell Ent NVMe CM6 MU 3.2TB",
+			Serial:       "Y0R0A03ETC98",
+			Firmware:     "2.1.3",
+			Transport:    "pcie",
+			Address:      "0000:5f:00.0",
+			VendorID:     "0x1e0f",
+			Subsystem:    "nvme-subsys1",
+			SubsystemNQN: "nqn.2019-10.com.kioxia:KCM6XVUL3T20:Y0R0A03ETC98",
+			Namespaces:   []string{"nvme1n1"},
+		},
+	}
+	if !reflect.DeepEqual(topology.Controllers, expectedControllers) {
+		t.Errorf("Expected controllers %+v, got %+v", expectedControllers, topology.Controllers)
+	}
+
+	// sda is not an NVMe namespace and the multipath namespace is listed once.
+	expectedNamespaces := []Namespace{
+		{Name: "nvme0n1", Subsystem: "nvme-subsys0", Controllers: []string{"nvme0"}},
+		{Name: "nvme1n1", Subsystem: "nvme-subsys1", Controllers: []string{"nvme1", "nvme2"}},
+	}
+	if !reflect.DeepEqual(topology.Namespaces, expectedNamespaces) {
+		t.Errorf("Expected namespaces %+v, got %+v", expectedNamespaces, topology.Namespaces)
+	}
+}
+
+func TestDiscoverWithoutNVMe(t *testing.T) {
+	topology, err := Discover("testdata/missing")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(topology.Controllers) != 0 || len(topology.Namespaces) != 0 {
+		t.Errorf("Expected an empty topology, got %+v", topology)
+	}
+}
+
+func TestGetNVMeDrivesFromSysfs(t *testing.T) {
+	drives, err := GetNVMeDrives("testdata/sysfs")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []string{"nvme0n1", "nvme1n1"}
+	if !reflect.DeepEqual(drives, expected) {
+		t.Errorf("Expected drives %v, got %v", expected, drives)
+	}
+}
+
+func TestCollectSysfsRoot(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(&MockCommandExecutor{MockError: errors.New("nvme not mocked")}, registry, 5*time.Minute)
+	metrics.SysfsRoot = "testdata/sysfs"
+
+	expected := `
+# HELP nvme_presence Presence of NVMe devices
+# TYPE nvme_presence gauge
+nvme_presence{device="nvme0n1"} 1
+nvme_presence{device="nvme1n1"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nvme_presence"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestCollectControllerInfo(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(&MockCommandExecutor{MockError: errors.New("nvme not mocked")}, registry, 5*time.Minute)
+	metrics.SysfsRoot = "testdata/sysfs"
+
+	// Both paths of the multipath namespace nvme1n1 are listed
+	expected := `
+# HELP nvme_controller_info Attributes of the NVMe controller read from sysfs, always 1
+# TYPE nvme_controller_info gauge
+nvme_controller_info{address="0000:3d:00.0",controller="nvme0",firmware="2.1.8",model="Dell Ent NVMe v2 AGN RI U.2 1.92TB",serial="S6CRNA0R500123",subsystem="nvme-subsys0",transport="pcie"} 1
+nvme_controller_info{address="0000:5e:00.0",controller="nvme1",firmware="2.1.3",model="Dell Ent NVMe CM6 MU 3.2TB",serial="Y0R0A03ETC98",subsystem="nvme-subsys1",transport="pcie"} 1
+nvme_controller_info{address="0000:5f:00.0",controller="nvme2",firmware="2.1.3",model="Dell Ent NVMe CM6 MU 3.2TB",serial="Y0R0A03ETC98",subsystem="nvme-subsys1",transport="pcie"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nvme_controller_info"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
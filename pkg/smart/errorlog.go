@@ -0,0 +1,168 @@
+package smart
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// recentErrorsPerController is the number of error log entries kept per
+// controller for ServeErrorLog.
+const recentErrorsPerController = 16
+
+// ErrorLogEntry is an entry of the NVMe error information log page read with
+// nvme error-log. Entries with a zero ErrorCount are unused.
+type ErrorLogEntry struct {
+	// ErrorCount is a unique, incrementing identifier of the error.
+	ErrorCount        uint64 `json:"error_count"`
+	SQID              int    `json:"sqid"`
+	CmdID             int    `json:"cmdid"`
+	StatusField       int    `json:"status_field"`
+	ParmErrorLocation int    `json:"parm_error_location"`
+	LBA               uint64 `json:"lba"`
+	NSID              uint32 `json:"nsid"`
+	// PhaseTag is only printed by nvme-cli 2.x, which also strips it from
+	// StatusField. Opcode is only reported by NVMe 2.0 controllers.
+	PhaseTag *int `json:"phase_tag,omitempty"`
+	Opcode   *int `json:"opcode,omitempty"`
+}
+
+// statusCode returns the status code type and status code of the failed
+// command, e.g. "0x281" for an unrecovered read error.
+func (e *ErrorLogEntry) statusCode() string {
+	status := e.StatusField
+	if e.PhaseTag == nil {
+		status >>= 1
+	}
+	return fmt.Sprintf("0x%03x", status&0x7ff)
+}
+
+// opcodeName returns the opcode of the failed command, e.g. "0x02" for a read
+// on an I/O queue, or "unknown" when the controller does not report it.
+func (e *ErrorLogEntry) opcodeName() string {
+	if e.Opcode == nil {
+		return "unknown"
+	}
+	return fmt.Sprintf("0x%02x", *e.Opcode)
+}
+
+// RecentError is an error log entry as served by ServeErrorLog. Device is the
+// controller the entry was read from, e.g. nvme0.
+type RecentError struct {
+	ErrorLogEntry
+	Device     string    `json:"device"`
+	StatusCode string    `json:"status_code"`
+	OpcodeName string    `json:"opcode_name"`
+	Seen       time.Time `json:"seen"`
+}
+
+type errorLogKey struct {
+	statusCode string
+	opcode     string
+}
+
+// errorLogState tracks the entries of the error log of a controller already counted.
+type errorLogState struct {
+	lastErrorCount uint64
+	counts         map[errorLogKey]float64
+	recent         []RecentError
+}
+
+var errorLogEntriesDesc = prometheus.NewDesc(
+	"nvme_error_log_entries_total",
+	"Number of error log entries read since the exporter started, by status code and opcode of the failed command",
+	[]string{"device", "status_code", "opcode"}, nil,
+)
+
+// GetErrorLog returns the entries of the error information log of drive.
+func (m *Metrics) GetErrorLog(drive string) ([]ErrorLogEntry, error) {
+	output, err := m.executor.ExecuteCommand("nvme", "error-log", "/dev/"+drive, "--output-format", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var errorLog struct {
+		Errors []ErrorLogEntry `json:"errors"`
+	}
+	if err := json.Unmarshal(output, &errorLog); err != nil {
+		return nil, fmt.Errorf("error parsing nvme error-log output of %s: %w", drive, err)
+	}
+	return errorLog.Errors, nil
+}
+
+// recordErrorLog counts the entries of the error log of controller that were
+// not seen at the previous read. The log is a ring buffer returned in full on
+// every read, entries are told apart by their ErrorCount. The first read only
+// seeds the last ErrorCount, so errors logged before the exporter started are
+// not counted.
+func (m *Metrics) recordErrorLog(controller string, entries []ErrorLogEntry) {
+	state, seen := m.errorLogs[controller]
+	if !seen {
+		state = &errorLogState{counts: make(map[errorLogKey]float64)}
+		m.errorLogs[controller] = state
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ErrorCount < entries[j].ErrorCount })
+	if seen && len(entries) > 0 && entries[len(entries)-1].ErrorCount < state.lastErrorCount {
+		// The error count went back, e.g. after a format or a controller replacement.
+		state.lastErrorCount = 0
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.ErrorCount == 0 || entry.ErrorCount <= state.lastErrorCount {
+			continue
+		}
+		if seen {
+			state.counts[errorLogKey{entry.statusCode(), entry.opcodeName()}]++
+		}
+		state.recent = append(state.recent, RecentError{
+			ErrorLogEntry: entry,
+			Device:        controller,
+			StatusCode:    entry.statusCode(),
+			OpcodeName:    entry.opcodeName(),
+			Seen:          now,
+		})
+		state.lastErrorCount = entry.ErrorCount
+	}
+	if len(state.recent) > recentErrorsPerController {
+		state.recent = state.recent[len(state.recent)-recentErrorsPerController:]
+	}
+}
+
+// collectErrorLog sends nvme_error_log_entries_total for drive, from the error
+// log of its controller.
+func collectErrorLog(ch chan<- prometheus.Metric, drive string, state *errorLogState) {
+	for key, count := range state.counts {
+		ch <- prometheus.MustNewConstMetric(errorLogEntriesDesc, prometheus.CounterValue, count, drive, key.statusCode, key.opcode)
+	}
+}
+
+// ServeErrorLog serves the error log entries most recently read from every
+// controller as JSON, keyed by controller and newest last. The device query
+// parameter selects a controller, given by its name or one of its namespaces.
+// The entries are those of the last collection, it does not read the drives.
+func (m *Metrics) ServeErrorLog(w http.ResponseWriter, r *http.Request) {
+	device := r.URL.Query().Get("device")
+
+	m.mu.Lock()
+	recent := make(map[string][]RecentError)
+	for controller, state := range m.errorLogs {
+		if device != "" && controller != device && controller != namespaceController(device) {
+			continue
+		}
+		recent[controller] = append([]RecentError(nil), state.recent...)
+	}
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(recent); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,155 @@
+package smart
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectErrorLog(t *testing.T) {
+	mockExecutor := newNVMeMockExecutor()
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(mockExecutor, registry, 5*time.Minute)
+	if err := metrics.Update(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	mockExecutor.Outputs[errorLogCommand] = readTestdata(t, "error-log/nvme-cli-2.8.json")
+	expected := `
+# HELP nvme_error_log_entries_total Number of error log entries read since the exporter started, by status code and opcode of the failed command
+# TYPE nvme_error_log_entries_total counter
+nvme_error_log_entries_total{device="nvme0n1",opcode="0x02",status_code="0x281"} 2
+nvme_error_log_entries_total{device="nvme0n1",opcode="0x06",status_code="0x002"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nvme_error_log_entries_total"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+
+	// The same entries are read again along with a new one, only the new one is counted
+	mockExecutor.Outputs[errorLogCommand] = `{"errors" : [
+  {"error_count" : 20, "sqid" : 3, "cmdid" : 51, "status_field" : 641, "phase_tag" : 0, "opcode" : 2},
+  {"error_count" : 19, "sqid" : 3, "cmdid" : 42, "status_field" : 641, "phase_tag" : 0, "opcode" : 2},
+  {"error_count" : 18, "sqid" : 3, "cmdid" : 28, "status_field" : 641, "phase_tag" : 0, "opcode" : 2},
+  {"error_count" : 17, "sqid" : 0, "cmdid" : 4101, "status_field" : 2, "phase_tag" : 0, "opcode" : 6}
+]}`
+	expected = `
+# HELP nvme_error_log_entries_total Number of error log entries read since the exporter started, by status code and opcode of the failed command
+# TYPE nvme_error_log_entries_total counter
+nvme_error_log_entries_total{device="nvme0n1",opcode="0x02",status_code="0x281"} 3
+nvme_error_log_entries_total{device="nvme0n1",opcode="0x06",status_code="0x002"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nvme_error_log_entries_total"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestCollectErrorLogLegacyStatusField(t *testing.T) {
+	// nvme-cli 1.x prints the raw status field, with the phase tag, and no opcode
+	mockExecutor := newNVMeMockExecutor()
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(mockExecutor, registry, 5*time.Minute)
+	if err := metrics.Update(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	mockExecutor.Outputs[errorLogCommand] = readTestdata(t, "error-log/nvme-cli-1.12.json")
+	expected := `
+# HELP nvme_error_log_entries_total Number of error log entries read since the exporter started, by status code and opcode of the failed command
+# TYPE nvme_error_log_entries_total counter
+nvme_error_log_entries_total{device="nvme0n1",opcode="unknown",status_code="0x002"} 2
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nvme_error_log_entries_total"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestCollectErrorLogSeed(t *testing.T) {
+	// error-log is only mocked for nvme0n1, reading it for nvme0n2 would fail
+	mockExecutor := newNVMeMockExecutor()
+	mockExecutor.Outputs[errorLogCommand] = readTestdata(t, "error-log/nvme-cli-2.8.json")
+	for _, command := range []string{"smart-log", "id-ctrl", "id-ns", "self-test-log"} {
+		mockExecutor.Outputs["nvme "+command+" /dev/nvme0n2 --output-format json"] = mockExecutor.Outputs["nvme "+command+" /dev/nvme0n1 --output-format json"]
+	}
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = func(string) ([]string, error) { return []string{"nvme0n1", "nvme0n2"}, nil }
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(mockExecutor, registry, 5*time.Minute)
+
+	// The entries logged before the first read are not counted
+	if err := metrics.Update(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if count := testutil.CollectAndCount(registry, "nvme_error_log_entries_total"); count != 0 {
+		t.Fatalf("Expected no error log series, got %d", count)
+	}
+
+	mockExecutor.Outputs[errorLogCommand] = `{"errors" : [
+  {"error_count" : 20, "sqid" : 3, "cmdid" : 51, "status_field" : 641, "phase_tag" : 0, "opcode" : 2},
+  {"error_count" : 19, "sqid" : 3, "cmdid" : 42, "status_field" : 641, "phase_tag" : 0, "opcode" : 2}
+]}`
+	expected := `
+# HELP nvme_error_log_entries_total Number of error log entries read since the exporter started, by status code and opcode of the failed command
+# TYPE nvme_error_log_entries_total counter
+nvme_error_log_entries_total{device="nvme0n1",opcode="0x02",status_code="0x281"} 1
+nvme_error_log_entries_total{device="nvme0n2",opcode="0x02",status_code="0x281"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nvme_error_log_entries_total"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+	if count := testutil.CollectAndCount(registry, "dell_disk_exporter_command_errors_total"); count != 0 {
+		t.Fatalf("Expected no command error, got %d series", count)
+	}
+}
+
+func TestServeErrorLog(t *testing.T) {
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	mockExecutor := newNVMeMockExecutor()
+	mockExecutor.Outputs[errorLogCommand] = readTestdata(t, "error-log/nvme-cli-2.8.json")
+	metrics := NewMetrics(mockExecutor, prometheus.NewRegistry(), 5*time.Minute)
+	if err := metrics.Update(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The namespace selects the error log of its controller
+	recorder := httptest.NewRecorder()
+	metrics.ServeErrorLog(recorder, httptest.NewRequest(http.MethodGet, "/debug/nvme/error-log?device=nvme0n1", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", recorder.Code)
+	}
+
+	var recent map[string][]RecentError
+	if err := json.Unmarshal(recorder.Body.Bytes(), &recent); err != nil {
+		t.Fatalf("Expected a JSON response, got %v:\n%s", err, recorder.Body.String())
+	}
+	entries := recent["nvme0"]
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 recent errors, got %+v", entries)
+	}
+	// Newest last
+	last := entries[len(entries)-1]
+	if last.ErrorCount != 19 || last.StatusCode != "0x281" || last.OpcodeName != "0x02" || last.LBA != 123456789 {
+		t.Fatalf("Unexpected last error %+v", last)
+	}
+}
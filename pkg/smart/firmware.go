@@ -0,0 +1,171 @@
+package smart
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// FirmwareLog is the firmware slot information log read with nvme fw-log.
+type FirmwareLog struct {
+	// ActiveSlot is the slot of the running firmware, 1 to 7.
+	ActiveSlot int
+	// NextSlot is the slot activated at the next controller reset, 0 if none.
+	NextSlot int
+	// Revisions are the firmware revisions keyed by slot. Empty slots are omitted.
+	Revisions map[int]string
+}
+
+// ActiveRevision returns the revision of the running firmware.
+func (l *FirmwareLog) ActiveRevision() string {
+	return l.Revisions[l.ActiveSlot]
+}
+
+// Pending reports whether another firmware is activated at the next
+// controller reset. Some controllers report the active slot as the next one.
+func (l *FirmwareLog) Pending() bool {
+	return l.NextSlot != 0 && l.NextSlot != l.ActiveSlot
+}
+
+var (
+	firmwareSlotKey = regexp.MustCompile(`^Firmware Rev Slot (\d+)$`)
+	// firmwareRevision matches the revision nvme-cli prints after the raw
+	// value of a slot, e.g. "3689099298585592918 (VDV1DP23)".
+	firmwareRevision = regexp.MustCompile(`\((.*)\)\s*$`)
+
+	firmwareActiveSlotDesc = prometheus.NewDesc(
+		"nvme_firmware_active_slot",
+		"Firmware slot the controller is running from",
+		[]string{"device"}, nil,
+	)
+	firmwareSlotInfoDesc = prometheus.NewDesc(
+		"nvme_firmware_slot_info",
+		"Firmware revision stored in each slot of the controller, always 1",
+		[]string{"device", "slot", "revision"}, nil,
+	)
+	firmwarePendingDesc = prometheus.NewDesc(
+		"nvme_firmware_pending_activation",
+		"Whether a firmware is staged for activation at the next controller reset",
+		[]string{"device"}, nil,
+	)
+	firmwareApprovedDesc = prometheus.NewDesc(
+		"nvme_firmware_approved",
+		"Whether the running firmware is in the approved firmware list of the drive model",
+		[]string{"device"}, nil,
+	)
+)
+
+// GetFirmwareLog returns the firmware slot information log of the controller
+// behind drive. The log is the same for every namespace of the controller.
+func (m *Metrics) GetFirmwareLog(drive string) (*FirmwareLog, error) {
+	output, err := m.executor.ExecuteCommand("nvme", "fw-log", "/dev/"+drive, "--output-format", "json")
+	if err != nil {
+		return nil, err
+	}
+	firmwareLog, err := parseFirmwareLog(output)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing nvme fw-log output of %s: %w", drive, err)
+	}
+	return firmwareLog, nil
+}
+
+// parseFirmwareLog parses the JSON output of nvme fw-log, an object holding
+// the log under the name of the device.
+func parseFirmwareLog(output []byte) (*FirmwareLog, error) {
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	// The raw slot values do not fit in a float64
+	decoder.UseNumber()
+	var devices map[string]map[string]interface{}
+	if err := decoder.Decode(&devices); err != nil {
+		return nil, err
+	}
+	if len(devices) != 1 {
+		return nil, fmt.Errorf("expected the log of one device, got %d", len(devices))
+	}
+
+	firmwareLog := &FirmwareLog{Revisions: make(map[int]string)}
+	for _, fields := range devices {
+		afi, err := strconv.Atoi(fmt.Sprint(fields["Active Firmware Slot (afi)"]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid active firmware slot: %w", err)
+		}
+		// Bits 0-2 hold the active slot, bits 4-6 the slot of the next reset
+		firmwareLog.ActiveSlot = afi & 0x7
+		firmwareLog.NextSlot = afi >> 4 & 0x7
+
+		for key, value := range fields {
+			match := firmwareSlotKey.FindStringSubmatch(key)
+			if match == nil {
+				continue
+			}
+			slot, _ := strconv.Atoi(match[1])
+			if revision := firmwareSlotRevision(fmt.Sprint(value)); revision != "" {
+				firmwareLog.Revisions[slot] = revision
+			}
+		}
+	}
+	return firmwareLog, nil
+}
+
+// firmwareSlotRevision returns the revision of a slot printed either as
+// "<raw value> (<revision>)" or as the raw value alone, the 8 ASCII characters
+// of the revision read as a little-endian integer.
+func firmwareSlotRevision(value string) string {
+	if match := firmwareRevision.FindStringSubmatch(value); match != nil {
+		return strings.TrimSpace(match[1])
+	}
+	raw, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return strings.TrimSpace(value)
+	}
+	revision := make([]byte, 8)
+	binary.LittleEndian.PutUint64(revision, raw)
+	return strings.TrimSpace(string(bytes.TrimRight(revision, "\x00")))
+}
+
+// firmwareApproved reports whether firmware is listed for model in approved,
+// the approved firmware revisions keyed by model. Drives of unlisted models
+// are not approved.
+func firmwareApproved(approved map[string][]string, model, firmware string) bool {
+	for _, revision := range approved[model] {
+		if revision == firmware {
+			return true
+		}
+	}
+	return false
+}
+
+func describeFirmware(ch chan<- *prometheus.Desc) {
+	ch <- firmwareActiveSlotDesc
+	ch <- firmwareSlotInfoDesc
+	ch <- firmwarePendingDesc
+	ch <- firmwareApprovedDesc
+}
+
+// collectFirmwareLog sends the firmware slot metrics of drive.
+func collectFirmwareLog(ch chan<- prometheus.Metric, drive string, firmwareLog *FirmwareLog) {
+	ch <- prometheus.MustNewConstMetric(firmwareActiveSlotDesc, prometheus.GaugeValue, float64(firmwareLog.ActiveSlot), drive)
+	pending := 0.0
+	if firmwareLog.Pending() {
+		pending = 1
+	}
+	ch <- prometheus.MustNewConstMetric(firmwarePendingDesc, prometheus.GaugeValue, pending, drive)
+	for slot, revision := range firmwareLog.Revisions {
+		ch <- prometheus.MustNewConstMetric(firmwareSlotInfoDesc, prometheus.GaugeValue, 1, drive, strconv.Itoa(slot), revision)
+	}
+}
+
+// collectFirmwareApproved sends nvme_firmware_approved for drive.
+func collectFirmwareApproved(ch chan<- prometheus.Metric, drive string, approved bool) {
+	value := 0.0
+	if approved {
+		value = 1
+	}
+	ch <- prometheus.MustNewConstMetric(firmwareApprovedDesc, prometheus.GaugeValue, value, drive)
+}
@@ -0,0 +1,156 @@
+package smart
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseFirmwareLog(t *testing.T) {
+	firmwareLog, err := parseFirmwareLog([]byte(readTestdata(t, "fw-log/pending.json")))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if firmwareLog.ActiveSlot != 1 || firmwareLog.NextSlot != 2 || firmwareLog.ActiveRevision() != "VDV1DP23" ||
+		firmwareLog.Revisions[2] != "VDV1DP25" || len(firmwareLog.Revisions) != 2 {
+		t.Fatalf("Unexpected firmware log %+v", firmwareLog)
+	}
+}
+
+func TestFirmwareSlotRevision(t *testing.T) {
+	tests := map[string]string{
+		"3689099298585592918 (VDV1DP23)": "VDV1DP23",
+		// Raw value alone, as printed by some nvme-cli versions
+		"3689099298585592918": "VDV1DP23",
+		// Revisions shorter than 8 characters are padded with spaces
+		"2314885531104718896": "0001",
+		"GDC5602Q":            "GDC5602Q",
+	}
+	for value, expected := range tests {
+		if revision := firmwareSlotRevision(value); revision != expected {
+			t.Errorf("firmwareSlotRevision(%q) = %q, expected %q", value, revision, expected)
+		}
+	}
+}
+
+func TestCollectFirmware(t *testing.T) {
+	mockExecutor := newNVMeMockExecutor()
+	mockExecutor.Outputs[firmwareLogCommand] = readTestdata(t, "fw-log/pending.json")
+	mockExecutor.Outputs[idCtrlCommand] = `{"mn" : "Dell Express Flash NVMe P4610 1.6TB SFF ", "fr" : "VDV1DP23"}`
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(mockExecutor, registry, 5*time.Minute)
+	metrics.ApprovedFirmware = map[string][]string{
+		"Dell Express Flash NVMe P4610 1.6TB SFF": {"VDV1DP25"},
+	}
+
+	expected := `
+# HELP nvme_firmware_active_slot Firmware slot the controller is running from
+# TYPE nvme_firmware_active_slot gauge
+nvme_firmware_active_slot{device="nvme0n1"} 1
+# HELP nvme_firmware_approved Whether the running firmware is in the approved firmware list of the drive model
+# TYPE nvme_firmware_approved gauge
+nvme_firmware_approved{device="nvme0n1"} 0
+# HELP nvme_firmware_pending_activation Whether a firmware is staged for activation at the next controller reset
+# TYPE nvme_firmware_pending_activation gauge
+nvme_firmware_pending_activation{device="nvme0n1"} 1
+# HELP nvme_firmware_slot_info Firmware revision stored in each slot of the controller, always 1
+# TYPE nvme_firmware_slot_info gauge
+nvme_firmware_slot_info{device="nvme0n1",revision="VDV1DP23",slot="1"} 1
+nvme_firmware_slot_info{device="nvme0n1",revision="VDV1DP25",slot="2"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nvme_firmware_active_slot",
+		"nvme_firmware_approved", "nvme_firmware_pending_activation", "nvme_firmware_slot_info"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+
+	// Once the new firmware is activated the controller identity is read again
+	mockExecutor.Outputs[firmwareLogCommand] = readTestdata(t, "fw-log/active.json")
+	mockExecutor.Outputs[idCtrlCommand] = `{"mn" : "Dell Express Flash NVMe P4610 1.6TB SFF ", "fr" : "VDV1DP25"}`
+
+	expected = `
+# HELP nvme_device_info Identity of the controller behind the NVMe drive, always 1
+# TYPE nvme_device_info gauge
+nvme_device_info{device="nvme0n1",firmware="VDV1DP25",model="Dell Express Flash NVMe P4610 1.6TB SFF",serial="",subsystem_nqn="",vendor_id="0x0000"} 1
+# HELP nvme_firmware_approved Whether the running firmware is in the approved firmware list of the drive model
+# TYPE nvme_firmware_approved gauge
+nvme_firmware_approved{device="nvme0n1"} 1
+# HELP nvme_firmware_pending_activation Whether a firmware is staged for activation at the next controller reset
+# TYPE nvme_firmware_pending_activation gauge
+nvme_firmware_pending_activation{device="nvme0n1"} 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nvme_device_info",
+		"nvme_firmware_approved", "nvme_firmware_pending_activation"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestCollectFirmwareWithoutAllowList(t *testing.T) {
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	mockExecutor := newNVMeMockExecutor()
+	mockExecutor.Outputs[firmwareLogCommand] = readTestdata(t, "fw-log/active.json")
+	mockExecutor.Outputs[idCtrlCommand] = `{"mn" : "Dell Express Flash NVMe P4610 1.6TB SFF ", "fr" : "VDV1DP25"}`
+
+	registry := prometheus.NewRegistry()
+	NewMetrics(mockExecutor, registry, 5*time.Minute)
+
+	if count := testutil.CollectAndCount(registry, "nvme_firmware_approved"); count != 0 {
+		t.Fatalf("Expected no nvme_firmware_approved series, got %d", count)
+	}
+}
+
+func TestFirmwarePending(t *testing.T) {
+	tests := map[string]struct {
+		firmwareLog FirmwareLog
+		pending     bool
+	}{
+		"no next slot": {FirmwareLog{ActiveSlot: 1}, false},
+		"other slot":   {FirmwareLog{ActiveSlot: 1, NextSlot: 2}, true},
+		// Some controllers report the running slot as the next one
+		"active slot": {FirmwareLog{ActiveSlot: 2, NextSlot: 2}, false},
+	}
+	for name, test := range tests {
+		if pending := test.firmwareLog.Pending(); pending != test.pending {
+			t.Errorf("%s: expected pending %v, got %v", name, test.pending, pending)
+		}
+	}
+}
+
+func TestCollectFirmwareOncePerController(t *testing.T) {
+	// fw-log is only mocked for nvme0n1, reading it for nvme0n2 would fail
+	mockExecutor := newNVMeMockExecutor()
+	mockExecutor.Outputs[firmwareLogCommand] = readTestdata(t, "fw-log/pending.json")
+	for _, command := range []string{"smart-log", "id-ctrl", "id-ns", "self-test-log"} {
+		mockExecutor.Outputs["nvme "+command+" /dev/nvme0n2 --output-format json"] = mockExecutor.Outputs["nvme "+command+" /dev/nvme0n1 --output-format json"]
+	}
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = func(string) ([]string, error) { return []string{"nvme0n1", "nvme0n2"}, nil }
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	NewMetrics(mockExecutor, registry, 5*time.Minute)
+
+	expected := `
+# HELP nvme_firmware_pending_activation Whether a firmware is staged for activation at the next controller reset
+# TYPE nvme_firmware_pending_activation gauge
+nvme_firmware_pending_activation{device="nvme0n1"} 1
+nvme_firmware_pending_activation{device="nvme0n2"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nvme_firmware_pending_activation"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+	if count := testutil.CollectAndCount(registry, "dell_disk_exporter_command_errors_total"); count != 0 {
+		t.Fatalf("Expected no command error, got %d series", count)
+	}
+}
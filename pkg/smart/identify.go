@@ -0,0 +1,209 @@
+package smart
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// controllerIdentity is the subset of the nvme id-ctrl output exported as
+// nvme_device_info.
+type controllerIdentity struct {
+	model        string
+	serial       string
+	firmware     string
+	vendorID     uint16
+	subsystemNQN string
+}
+
+// namespaceIdentity is the subset of the nvme id-ns output exported as metrics.
+// Sizes are in logical blocks of lbaSize bytes.
+type namespaceIdentity struct {
+	size         float64
+	capacity     float64
+	utilization  float64
+	lbaFormat    int
+	lbaSize      float64
+	metadataSize float64
+}
+
+var (
+	deviceInfoDesc = prometheus.NewDesc(
+		"nvme_device_info",
+		"Identity of the controller behind the NVMe drive, always 1",
+		[]string{"device", "model", "serial", "firmware", "vendor_id", "subsystem_nqn"}, nil,
+	)
+	namespaceSizeDesc = prometheus.NewDesc(
+		"nvme_namespace_size_bytes",
+		"Total size of the namespace in bytes (NSZE)",
+		[]string{"device"}, nil,
+	)
+	namespaceCapacityDesc = prometheus.NewDesc(
+		"nvme_namespace_capacity_bytes",
+		"Maximum number of bytes that may be allocated in the namespace (NCAP)",
+		[]string{"device"}, nil,
+	)
+	namespaceUtilizationDesc = prometheus.NewDesc(
+		"nvme_namespace_utilization_bytes",
+		"Number of bytes currently allocated in the namespace (NUSE)",
+		[]string{"device"}, nil,
+	)
+	namespaceLBAFormatDesc = prometheus.NewDesc(
+		"nvme_namespace_lba_format",
+		"Index of the LBA format the namespace is formatted with",
+		[]string{"device"}, nil,
+	)
+	namespaceLBASizeDesc = prometheus.NewDesc(
+		"nvme_namespace_lba_size_bytes",
+		"Size of the logical blocks of the namespace in bytes",
+		[]string{"device"}, nil,
+	)
+	namespaceMetadataSizeDesc = prometheus.NewDesc(
+		"nvme_namespace_metadata_size_bytes",
+		"Size of the metadata stored with each logical block of the namespace in bytes",
+		[]string{"device"}, nil,
+	)
+
+	// textLBAFormat matches the LBA formats printed by nvme id-ns, e.g.
+	// "ms:0   lbads:9  rp:0x2 (in use)".
+	textLBAFormat = regexp.MustCompile(`ms:\s*(\d+)\s+lbads:\s*(\d+)`)
+)
+
+// GetIDNS returns the identify namespace data of drive.
+func (m *Metrics) GetIDNS(drive string) (map[string]interface{}, error) {
+	output, err := m.executor.ExecuteCommand("nvme", "id-ns", "/dev/"+drive, "--output-format", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var idNS map[string]interface{}
+	if err := json.Unmarshal(output, &idNS); err != nil {
+		return parseIDNSText(string(output)), nil
+	}
+
+	return idNS, nil
+}
+
+// parseIDNSText parses the text output of nvme id-ns into the fields of its
+// JSON output. The "lbaf  N" lines become the lbafs list.
+func parseIDNSText(output string) map[string]interface{} {
+	idNS := make(map[string]interface{})
+	var lbafs []interface{}
+	for key, value := range parseNvmeText(output) {
+		if index, found := strings.CutPrefix(key, "lbaf_"); found {
+			n, err := strconv.Atoi(index)
+			match := textLBAFormat.FindStringSubmatch(value)
+			if err != nil || match == nil {
+				continue
+			}
+			for len(lbafs) <= n {
+				lbafs = append(lbafs, nil)
+			}
+			ms, _ := strconv.ParseFloat(match[1], 64)
+			ds, _ := strconv.ParseFloat(match[2], 64)
+			lbafs[n] = map[string]interface{}{"ms": ms, "ds": ds}
+			continue
+		}
+		if number, ok := parseTextNumber(value); ok {
+			idNS[key] = number
+		}
+	}
+	if lbafs != nil {
+		idNS["lbafs"] = lbafs
+	}
+	return idNS
+}
+
+// parseControllerIdentity reads the model, serial number, firmware revision,
+// PCI vendor ID and subsystem NQN from the nvme id-ctrl output. nvme-cli pads
+// the strings with spaces, they are trimmed.
+func parseControllerIdentity(idCtrl map[string]interface{}) controllerIdentity {
+	identity := controllerIdentity{
+		model:        stringValue(idCtrl["mn"]),
+		serial:       stringValue(idCtrl["sn"]),
+		firmware:     stringValue(idCtrl["fr"]),
+		subsystemNQN: stringValue(idCtrl["subnqn"]),
+	}
+	vid, ok := numericValue(idCtrl["vid"])
+	if !ok {
+		// The text output prints the vendor ID in hexadecimal
+		vid, ok = parseTextNumber(stringValue(idCtrl["vid"]))
+	}
+	if ok && vid >= 0 && vid <= math.MaxUint16 {
+		identity.vendorID = uint16(vid)
+	}
+	return identity
+}
+
+// parseNamespaceIdentity reads the sizes and the LBA format in use from the
+// nvme id-ns output. It returns false when the LBA format in use is not listed,
+// as the sizes cannot be converted to bytes.
+func parseNamespaceIdentity(idNS map[string]interface{}) (namespaceIdentity, bool) {
+	var identity namespaceIdentity
+	flbas, _ := numericValue(idNS["flbas"])
+	// Bits 0-3 hold the low bits of the format index, bits 5-6 the high bits
+	// when more than 16 formats are supported.
+	format := int(flbas) & 0x0f
+	if nlbaf, _ := numericValue(idNS["nlbaf"]); nlbaf >= 16 {
+		format |= (int(flbas) & 0x60) >> 1
+	}
+	identity.lbaFormat = format
+
+	lbafs, _ := idNS["lbafs"].([]interface{})
+	if format >= len(lbafs) {
+		return identity, false
+	}
+	lbaf, ok := lbafs[format].(map[string]interface{})
+	if !ok {
+		return identity, false
+	}
+	ds, ok := numericValue(lbaf["ds"])
+	if !ok {
+		return identity, false
+	}
+	identity.lbaSize = math.Exp2(ds)
+	identity.metadataSize, _ = numericValue(lbaf["ms"])
+
+	identity.size, _ = numericValue(idNS["nsze"])
+	identity.capacity, _ = numericValue(idNS["ncap"])
+	identity.utilization, _ = numericValue(idNS["nuse"])
+	return identity, true
+}
+
+// stringValue returns value trimmed if it is a string, or an empty string.
+func stringValue(value interface{}) string {
+	s, _ := value.(string)
+	return strings.TrimSpace(s)
+}
+
+func describeIdentity(ch chan<- *prometheus.Desc) {
+	ch <- deviceInfoDesc
+	ch <- namespaceSizeDesc
+	ch <- namespaceCapacityDesc
+	ch <- namespaceUtilizationDesc
+	ch <- namespaceLBAFormatDesc
+	ch <- namespaceLBASizeDesc
+	ch <- namespaceMetadataSizeDesc
+}
+
+// collectControllerIdentity sends nvme_device_info for drive.
+func collectControllerIdentity(ch chan<- prometheus.Metric, drive string, identity *controllerIdentity) {
+	ch <- prometheus.MustNewConstMetric(deviceInfoDesc, prometheus.GaugeValue, 1,
+		drive, identity.model, identity.serial, identity.firmware,
+		fmt.Sprintf("0x%04x", identity.vendorID), identity.subsystemNQN)
+}
+
+// collectNamespaceIdentity sends the namespace metrics of drive.
+func collectNamespaceIdentity(ch chan<- prometheus.Metric, drive string, identity *namespaceIdentity) {
+	ch <- prometheus.MustNewConstMetric(namespaceSizeDesc, prometheus.GaugeValue, identity.size*identity.lbaSize, drive)
+	ch <- prometheus.MustNewConstMetric(namespaceCapacityDesc, prometheus.GaugeValue, identity.capacity*identity.lbaSize, drive)
+	ch <- prometheus.MustNewConstMetric(namespaceUtilizationDesc, prometheus.GaugeValue, identity.utilization*identity.lbaSize, drive)
+	ch <- prometheus.MustNewConstMetric(namespaceLBAFormatDesc, prometheus.GaugeValue, float64(identity.lbaFormat), drive)
+	ch <- prometheus.MustNewConstMetric(namespaceLBASizeDesc, prometheus.GaugeValue, identity.lbaSize, drive)
+	ch <- prometheus.MustNewConstMetric(namespaceMetadataSizeDesc, prometheus.GaugeValue, identity.metadataSize, drive)
+}
@@ -0,0 +1,110 @@
+package smart
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectIdentity(t *testing.T) {
+	mockExecutor := newNVMeMockExecutor()
+	mockExecutor.Outputs[idCtrlCommand] = readTestdata(t, "identify/id-ctrl.json")
+	mockExecutor.Outputs[idNSCommand] = readTestdata(t, "identify/id-ns.json")
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	NewMetrics(mockExecutor, registry, 5*time.Minute)
+
+	expected := `
+# HELP nvme_device_info Identity of the controller behind the NVMe drive, always 1
+# TYPE nvme_device_info gauge
+nvme_device_info{device="nvme0n1",firmware="VDV1DP23",model="Dell Express Flash NVMe P4610 1.6TB SFF",serial="PHLN000000AA1P6AGN",subsystem_nqn="nqn.2014.08.org.nvmexpress:80868086PHLN000000AA1P6AGN  Dell Express Flash NVMe P4610 1.6TB SFF",vendor_id="0x8086"} 1
+# HELP nvme_namespace_capacity_bytes Maximum number of bytes that may be allocated in the namespace (NCAP)
+# TYPE nvme_namespace_capacity_bytes gauge
+nvme_namespace_capacity_bytes{device="nvme0n1"} 1.600321314816e+12
+# HELP nvme_namespace_lba_format Index of the LBA format the namespace is formatted with
+# TYPE nvme_namespace_lba_format gauge
+nvme_namespace_lba_format{device="nvme0n1"} 0
+# HELP nvme_namespace_lba_size_bytes Size of the logical blocks of the namespace in bytes
+# TYPE nvme_namespace_lba_size_bytes gauge
+nvme_namespace_lba_size_bytes{device="nvme0n1"} 512
+# HELP nvme_namespace_metadata_size_bytes Size of the metadata stored with each logical block of the namespace in bytes
+# TYPE nvme_namespace_metadata_size_bytes gauge
+nvme_namespace_metadata_size_bytes{device="nvme0n1"} 0
+# HELP nvme_namespace_size_bytes Total size of the namespace in bytes (NSZE)
+# TYPE nvme_namespace_size_bytes gauge
+nvme_namespace_size_bytes{device="nvme0n1"} 1.600321314816e+12
+# HELP nvme_namespace_utilization_bytes Number of bytes currently allocated in the namespace (NUSE)
+# TYPE nvme_namespace_utilization_bytes gauge
+nvme_namespace_utilization_bytes{device="nvme0n1"} 1.34217728e+11
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nvme_device_info",
+		"nvme_namespace_capacity_bytes", "nvme_namespace_lba_format", "nvme_namespace_lba_size_bytes",
+		"nvme_namespace_metadata_size_bytes", "nvme_namespace_size_bytes", "nvme_namespace_utilization_bytes"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestParseNamespaceIdentityText(t *testing.T) {
+	identity, ok := parseNamespaceIdentity(parseIDNSText(readTestdata(t, "identify/id-ns.txt")))
+	if !ok {
+		t.Fatal("Expected the LBA format in use to be found")
+	}
+
+	expected := namespaceIdentity{
+		size:         390703446,
+		capacity:     390703446,
+		utilization:  32768000,
+		lbaFormat:    1,
+		lbaSize:      4096,
+		metadataSize: 8,
+	}
+	if identity != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, identity)
+	}
+}
+
+func TestParseNamespaceIdentityUnknownFormat(t *testing.T) {
+	// Format 2 is in use but only two formats are listed
+	_, ok := parseNamespaceIdentity(map[string]interface{}{
+		"nsze":  float64(100),
+		"flbas": float64(2),
+		"lbafs": []interface{}{
+			map[string]interface{}{"ms": float64(0), "ds": float64(9)},
+			map[string]interface{}{"ms": float64(0), "ds": float64(12)},
+		},
+	})
+	if ok {
+		t.Fatal("Expected the namespace to be skipped")
+	}
+}
+
+func TestParseControllerIdentityText(t *testing.T) {
+	idCtrl := make(map[string]interface{})
+	for key, value := range parseNvmeText(`NVME Identify Controller:
+vid       : 0x8086
+ssvid     : 0x1028
+sn        : PHLN000000AA1P6AGN
+mn        : Dell Express Flash NVMe P4610 1.6TB SFF
+fr        : VDV1DP23
+subnqn    : nqn.2014.08.org.nvmexpress:80868086PHLN000000AA1P6AGN  Dell Express Flash NVMe P4610 1.6TB SFF
+`) {
+		idCtrl[key] = value
+	}
+
+	identity := parseControllerIdentity(idCtrl)
+	if identity.vendorID != 0x8086 || identity.model != "Dell Express Flash NVMe P4610 1.6TB SFF" ||
+		identity.serial != "PHLN000000AA1P6AGN" || identity.firmware != "VDV1DP23" {
+		t.Fatalf("Unexpected identity %+v", identity)
+	}
+	// The NQN contains colons, only the first one separates the key
+	if !strings.HasPrefix(identity.subsystemNQN, "nqn.2014.08.org.nvmexpress:8086") {
+		t.Fatalf("Unexpected subsystem NQN %q", identity.subsystemNQN)
+	}
+}
@@ -0,0 +1,167 @@
+package smart
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	intelSmartLogID     = 0xca
+	intelSmartLogLength = 512
+	// intelSmartItemLength is the length of an attribute of the Intel log:
+	// key, 2 reserved bytes, normalized value, reserved byte, 6 raw bytes and
+	// a reserved byte.
+	intelSmartItemLength = 12
+	// intelWriteUnit is the unit of the NAND and host bytes written attributes.
+	intelWriteUnit = 32 << 20
+)
+
+var (
+	intelNormalizedDesc = prometheus.NewDesc(
+		"nvme_intel_smart_normalized",
+		"Normalized value of the attributes of the Intel additional SMART log, 100 when new",
+		[]string{"device", "attribute"}, nil,
+	)
+	intelProgramFailDesc = prometheus.NewDesc(
+		"nvme_intel_program_fail_total",
+		"Number of NAND program failures",
+		[]string{"device"}, nil,
+	)
+	intelEraseFailDesc = prometheus.NewDesc(
+		"nvme_intel_erase_fail_total",
+		"Number of NAND erase failures",
+		[]string{"device"}, nil,
+	)
+	intelWearLevelingDesc = prometheus.NewDesc(
+		"nvme_intel_wear_leveling_erase_count",
+		"Erase count of the NAND blocks by statistic: min, max or avg",
+		[]string{"device", "stat"}, nil,
+	)
+	intelEndToEndErrorsDesc = prometheus.NewDesc(
+		"nvme_intel_end_to_end_detected_errors_total",
+		"Number of errors detected by the end-to-end data path protection",
+		[]string{"device"}, nil,
+	)
+	intelCRCErrorsDesc = prometheus.NewDesc(
+		"nvme_intel_crc_errors_total",
+		"Number of PCIe CRC errors",
+		[]string{"device"}, nil,
+	)
+	intelThermalThrottleDesc = prometheus.NewDesc(
+		"nvme_intel_thermal_throttle_ratio",
+		"Current thermal throttling of the drive, from 0 to 1",
+		[]string{"device"}, nil,
+	)
+	intelThermalThrottleEventsDesc = prometheus.NewDesc(
+		"nvme_intel_thermal_throttle_events_total",
+		"Number of thermal throttling events",
+		[]string{"device"}, nil,
+	)
+	intelNANDWrittenDesc = prometheus.NewDesc(
+		"nvme_intel_nand_written_bytes_total",
+		"Bytes written to the NAND media",
+		[]string{"device"}, nil,
+	)
+	intelHostWrittenDesc = prometheus.NewDesc(
+		"nvme_intel_host_written_bytes_total",
+		"Bytes written by the host",
+		[]string{"device"}, nil,
+	)
+
+	// intelAttributes names the attributes of the Intel log by key.
+	intelAttributes = map[byte]string{
+		0xab: "program_fail_count",
+		0xac: "erase_fail_count",
+		0xad: "wear_leveling",
+		0xb8: "end_to_end_error_detection_count",
+		0xc7: "crc_error_count",
+		0xea: "thermal_throttle_status",
+		0xf4: "nand_bytes_written",
+		0xf5: "host_bytes_written",
+	}
+)
+
+// IntelSmartLog decodes the Intel additional SMART log page (0xCA) of the
+// Intel and Solidigm drives, also printed by nvme intel smart-log-add.
+type IntelSmartLog struct{}
+
+// Name implements VendorLog.
+func (IntelSmartLog) Name() string {
+	return "IntelSmartLog"
+}
+
+// Args implements VendorLog.
+func (IntelSmartLog) Args(drive string) []string {
+	return rawLogArgs(drive, intelSmartLogID, intelSmartLogLength)
+}
+
+// Describe implements VendorLog.
+func (IntelSmartLog) Describe(ch chan<- *prometheus.Desc) {
+	ch <- intelNormalizedDesc
+	ch <- intelProgramFailDesc
+	ch <- intelEraseFailDesc
+	ch <- intelWearLevelingDesc
+	ch <- intelEndToEndErrorsDesc
+	ch <- intelCRCErrorsDesc
+	ch <- intelThermalThrottleDesc
+	ch <- intelThermalThrottleEventsDesc
+	ch <- intelNANDWrittenDesc
+	ch <- intelHostWrittenDesc
+}
+
+// Decode implements VendorLog. Attributes are read up to the first empty key,
+// unknown keys are skipped. It returns ErrUnsupportedLog when no attribute is
+// known.
+func (IntelSmartLog) Decode(data []byte) ([]VendorMetric, error) {
+	var metrics []VendorMetric
+	for offset := 0; offset+intelSmartItemLength <= len(data); offset += intelSmartItemLength {
+		item := data[offset : offset+intelSmartItemLength]
+		key := item[0]
+		if key == 0 {
+			break
+		}
+		attribute, found := intelAttributes[key]
+		if !found {
+			continue
+		}
+
+		metrics = append(metrics, VendorMetric{
+			Desc: intelNormalizedDesc, ValueType: prometheus.GaugeValue, Value: float64(item[3]), LabelValues: []string{attribute},
+		})
+		raw := leUint(item[5:11])
+		switch key {
+		case 0xab:
+			metrics = append(metrics, VendorMetric{Desc: intelProgramFailDesc, ValueType: prometheus.CounterValue, Value: raw})
+		case 0xac:
+			metrics = append(metrics, VendorMetric{Desc: intelEraseFailDesc, ValueType: prometheus.CounterValue, Value: raw})
+		case 0xad:
+			// Minimum, maximum and average erase counts of 2 bytes each
+			for i, stat := range []string{"min", "max", "avg"} {
+				value := binary.LittleEndian.Uint16(item[5+2*i:])
+				metrics = append(metrics, VendorMetric{
+					Desc: intelWearLevelingDesc, ValueType: prometheus.GaugeValue, Value: float64(value), LabelValues: []string{stat},
+				})
+			}
+		case 0xb8:
+			metrics = append(metrics, VendorMetric{Desc: intelEndToEndErrorsDesc, ValueType: prometheus.CounterValue, Value: raw})
+		case 0xc7:
+			metrics = append(metrics, VendorMetric{Desc: intelCRCErrorsDesc, ValueType: prometheus.CounterValue, Value: raw})
+		case 0xea:
+			// Throttling percentage on 1 byte followed by the event count on 4
+			metrics = append(metrics,
+				VendorMetric{Desc: intelThermalThrottleDesc, ValueType: prometheus.GaugeValue, Value: float64(item[5]) / 100},
+				VendorMetric{Desc: intelThermalThrottleEventsDesc, ValueType: prometheus.CounterValue, Value: leUint(item[6:10])},
+			)
+		case 0xf4:
+			metrics = append(metrics, VendorMetric{Desc: intelNANDWrittenDesc, ValueType: prometheus.CounterValue, Value: raw * intelWriteUnit})
+		case 0xf5:
+			metrics = append(metrics, VendorMetric{Desc: intelHostWrittenDesc, ValueType: prometheus.CounterValue, Value: raw * intelWriteUnit})
+		}
+	}
+	if len(metrics) == 0 {
+		return nil, fmt.Errorf("no Intel SMART attribute found: %w", ErrUnsupportedLog)
+	}
+	return metrics, nil
+}
@@ -0,0 +1,47 @@
+package smart
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeIntelSmartLog(t *testing.T) {
+	metrics, err := IntelSmartLog{}.Decode([]byte(readTestdata(t, "vendor-log/intel-ca.bin")))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := map[string]float64{
+		intelNormalizedDesc.String() + " wear_leveling":      98,
+		intelNormalizedDesc.String() + " program_fail_count": 100,
+		intelProgramFailDesc.String():                        3,
+		intelEraseFailDesc.String():                          1,
+		intelWearLevelingDesc.String() + " min":              1302,
+		intelWearLevelingDesc.String() + " max":              1520,
+		intelWearLevelingDesc.String() + " avg":              1411,
+		intelEndToEndErrorsDesc.String():                     0,
+		intelCRCErrorsDesc.String():                          7,
+		intelThermalThrottleDesc.String():                    0,
+		intelThermalThrottleEventsDesc.String():              4,
+		intelNANDWrittenDesc.String():                        91500 * 32 << 20,
+		intelHostWrittenDesc.String():                        61000 * 32 << 20,
+	}
+	values := vendorMetricValues(metrics)
+	for key, value := range expected {
+		actual, found := values[key]
+		if !found || actual != value {
+			t.Errorf("%s = %v, expected %v", key, actual, value)
+		}
+	}
+	// The timed workload attribute (0xE2) is not decoded
+	if len(values) != 19 {
+		t.Errorf("Expected 19 metrics, got %d", len(values))
+	}
+}
+
+func TestDecodeIntelSmartLogUnsupported(t *testing.T) {
+	// An empty key at offset 0 ends the attributes
+	if _, err := (IntelSmartLog{}).Decode(make([]byte, 512)); !errors.Is(err, ErrUnsupportedLog) {
+		t.Fatalf("Expected ErrUnsupportedLog, got %v", err)
+	}
+}
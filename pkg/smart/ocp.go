@@ -0,0 +1,113 @@
+package smart
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	ocpSmartLogID     = 0xc0
+	ocpSmartLogLength = 512
+)
+
+// ocpSmartLogGUID identifies the OCP SMART log page, AFD514C97C6F4F9CA4F2BFEA2810AFC5
+// stored little-endian at offset 496.
+var ocpSmartLogGUID = []byte{0xc5, 0xaf, 0x10, 0x28, 0xea, 0xbf, 0xf2, 0xa4, 0x9c, 0x4f, 0x6f, 0x7c, 0xc9, 0x14, 0xd5, 0xaf}
+
+// ocpField is a field of the OCP SMART log page decoded into a metric.
+type ocpField struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	offset    int
+	size      int
+	// scale converts the raw value to the unit of the metric.
+	scale float64
+}
+
+func newOCPField(name, help string, valueType prometheus.ValueType, offset, size int, scale float64) ocpField {
+	return ocpField{
+		desc:      prometheus.NewDesc(name, help, []string{"device"}, nil),
+		valueType: valueType,
+		offset:    offset,
+		size:      size,
+		scale:     scale,
+	}
+}
+
+// ocpFields are the fields of the SMART / Health Information Extended log
+// (0xC0) of the OCP Datacenter NVMe SSD specification, by offset.
+var ocpFields = []ocpField{
+	newOCPField("nvme_ocp_physical_media_written_bytes_total", "Bytes written to the NAND media, including write amplification", prometheus.CounterValue, 0, 16, 1),
+	newOCPField("nvme_ocp_physical_media_read_bytes_total", "Bytes read from the NAND media", prometheus.CounterValue, 16, 16, 1),
+	newOCPField("nvme_ocp_bad_user_nand_blocks", "Number of user NAND blocks retired", prometheus.GaugeValue, 32, 6, 1),
+	newOCPField("nvme_ocp_bad_system_nand_blocks", "Number of system NAND blocks retired", prometheus.GaugeValue, 40, 6, 1),
+	newOCPField("nvme_ocp_xor_recovery_total", "Number of times XOR recovery was invoked", prometheus.CounterValue, 48, 8, 1),
+	newOCPField("nvme_ocp_uncorrectable_read_errors_total", "Number of uncorrectable read errors", prometheus.CounterValue, 56, 8, 1),
+	newOCPField("nvme_ocp_soft_ecc_errors_total", "Number of errors corrected by soft ECC", prometheus.CounterValue, 64, 8, 1),
+	newOCPField("nvme_ocp_end_to_end_detected_errors_total", "Number of errors detected by the end-to-end data path protection", prometheus.CounterValue, 72, 4, 1),
+	newOCPField("nvme_ocp_end_to_end_corrected_errors_total", "Number of errors corrected by the end-to-end data path protection", prometheus.CounterValue, 76, 4, 1),
+	newOCPField("nvme_ocp_system_data_used_ratio", "Used endurance of the system data area, from 0 to 1", prometheus.GaugeValue, 80, 1, 0.01),
+	newOCPField("nvme_ocp_refresh_total", "Number of NAND blocks refreshed", prometheus.CounterValue, 81, 7, 1),
+	newOCPField("nvme_ocp_user_data_erase_count_max", "Maximum erase count of the user data NAND blocks", prometheus.GaugeValue, 88, 4, 1),
+	newOCPField("nvme_ocp_user_data_erase_count_min", "Minimum erase count of the user data NAND blocks", prometheus.GaugeValue, 92, 4, 1),
+	newOCPField("nvme_ocp_thermal_throttling_events_total", "Number of thermal throttling events", prometheus.CounterValue, 96, 1, 1),
+	newOCPField("nvme_ocp_thermal_throttling_status", "Current thermal throttling status: 0 unthrottled, 1 first level, 2 second level, 3 third level", prometheus.GaugeValue, 97, 1, 1),
+	newOCPField("nvme_ocp_pcie_correctable_errors_total", "Number of PCIe correctable errors", prometheus.CounterValue, 104, 8, 1),
+	newOCPField("nvme_ocp_incomplete_shutdowns_total", "Number of shutdowns that did not complete", prometheus.CounterValue, 112, 4, 1),
+	newOCPField("nvme_ocp_free_blocks_ratio", "Free NAND blocks, from 0 to 1", prometheus.GaugeValue, 120, 1, 0.01),
+	newOCPField("nvme_ocp_capacitor_health_ratio", "Health of the power loss protection capacitors, from 0 to 1", prometheus.GaugeValue, 128, 2, 0.01),
+	newOCPField("nvme_ocp_unaligned_io_total", "Number of unaligned I/O commands", prometheus.CounterValue, 136, 8, 1),
+	newOCPField("nvme_ocp_plp_start_total", "Number of times the power loss protection was triggered", prometheus.CounterValue, 160, 16, 1),
+	newOCPField("nvme_ocp_endurance_estimate_bytes", "Estimated number of bytes that may be written over the lifetime of the drive", prometheus.GaugeValue, 176, 16, 1),
+	newOCPField("nvme_ocp_pcie_link_retraining_total", "Number of PCIe link retrainings", prometheus.CounterValue, 192, 8, 1),
+	newOCPField("nvme_ocp_power_state_changes_total", "Number of power state changes", prometheus.CounterValue, 200, 8, 1),
+}
+
+// OCPSmartLog decodes the OCP SMART / Health Information Extended log page
+// (0xC0), also printed by nvme ocp smart-add-log.
+type OCPSmartLog struct{}
+
+// Name implements VendorLog.
+func (OCPSmartLog) Name() string {
+	return "OCPSmartLog"
+}
+
+// Args implements VendorLog.
+func (OCPSmartLog) Args(drive string) []string {
+	return rawLogArgs(drive, ocpSmartLogID, ocpSmartLogLength)
+}
+
+// Describe implements VendorLog.
+func (OCPSmartLog) Describe(ch chan<- *prometheus.Desc) {
+	for _, field := range ocpFields {
+		ch <- field.desc
+	}
+}
+
+// Decode implements VendorLog. It returns ErrUnsupportedLog when data does not
+// end with the GUID of the OCP SMART log.
+func (OCPSmartLog) Decode(data []byte) ([]VendorMetric, error) {
+	if len(data) < ocpSmartLogLength {
+		return nil, fmt.Errorf("OCP SMART log of %d bytes, expected %d: %w", len(data), ocpSmartLogLength, ErrUnsupportedLog)
+	}
+	if !bytes.Equal(data[496:512], ocpSmartLogGUID) {
+		return nil, fmt.Errorf("OCP SMART log GUID not found: %w", ErrUnsupportedLog)
+	}
+
+	metrics := make([]VendorMetric, 0, len(ocpFields))
+	for _, field := range ocpFields {
+		value := field.data(data)
+		metrics = append(metrics, VendorMetric{Desc: field.desc, ValueType: field.valueType, Value: value * field.scale})
+	}
+	return metrics, nil
+}
+
+// data returns the raw value of the field in the log page data.
+func (f ocpField) data(data []byte) float64 {
+	if f.size == 16 {
+		return leUint128(data[f.offset:])
+	}
+	return leUint(data[f.offset : f.offset+f.size])
+}
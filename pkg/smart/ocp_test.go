@@ -0,0 +1,64 @@
+package smart
+
+import (
+	"errors"
+	"testing"
+)
+
+// vendorMetricValues returns the values of metrics by metric name, followed by
+// the label values after the device.
+func vendorMetricValues(metrics []VendorMetric) map[string]float64 {
+	values := make(map[string]float64)
+	for _, metric := range metrics {
+		key := metric.Desc.String()
+		for _, value := range metric.LabelValues {
+			key += " " + value
+		}
+		values[key] = metric.Value
+	}
+	return values
+}
+
+func TestDecodeOCPSmartLog(t *testing.T) {
+	metrics, err := OCPSmartLog{}.Decode([]byte(readTestdata(t, "vendor-log/ocp-c0.bin")))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(metrics) != len(ocpFields) {
+		t.Fatalf("Expected %d metrics, got %d", len(ocpFields), len(metrics))
+	}
+
+	expected := map[*ocpField]float64{
+		// 2 * 2^64 + 1234567890123
+		&ocpFields[0]:  36893488147419103232 + 1234567890123,
+		&ocpFields[1]:  987654321098,
+		&ocpFields[2]:  12,
+		&ocpFields[7]:  1,
+		&ocpFields[9]:  0.03,
+		&ocpFields[10]: 17,
+		&ocpFields[11]: 1520,
+		&ocpFields[13]: 4,
+		&ocpFields[14]: 1,
+		&ocpFields[17]: 0.97,
+		&ocpFields[18]: 1,
+		&ocpFields[21]: 8760000000000000,
+		&ocpFields[23]: 384,
+	}
+	values := vendorMetricValues(metrics)
+	for field, value := range expected {
+		if values[field.desc.String()] != value {
+			t.Errorf("%s = %v, expected %v", field.desc, values[field.desc.String()], value)
+		}
+	}
+}
+
+func TestDecodeOCPSmartLogWithoutGUID(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"no GUID":   []byte(readTestdata(t, "vendor-log/ocp-c0-no-guid.bin")),
+		"too short": []byte(readTestdata(t, "vendor-log/ocp-c0.bin"))[:256],
+	} {
+		if _, err := (OCPSmartLog{}).Decode(data); !errors.Is(err, ErrUnsupportedLog) {
+			t.Errorf("%s: expected ErrUnsupportedLog, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package smart
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a cron schedule: minute, hour, day of month, month and day of
+// week, matched in the time zone of the times given to Next.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record a day of month or day of week covering its
+	// whole range, e.g. "*" or "*/1". When both are restricted, a day matching
+	// either runs the schedule, as with cron.
+	domStar, dowStar bool
+}
+
+// allDaysOfMonth and allDaysOfWeek are the bit sets of unrestricted day fields.
+const (
+	allDaysOfMonth uint64 = 1<<32 - 2 // 1-31
+	allDaysOfWeek  uint64 = 1<<7 - 1  // 0-6
+)
+
+// scheduleAliases are the predefined schedules of cron.
+var scheduleAliases = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// ParseSchedule parses a cron expression of five fields, e.g. "0 3 * * 0" for
+// Sundays at 3:00. Fields accept "*", values, ranges such as "1-5", lists such
+// as "1,15" and steps such as "*/10". Days of week are 0 (Sunday) to 6, or 7
+// for Sunday. @hourly, @daily, @weekly and @monthly are accepted too.
+func ParseSchedule(spec string) (*Schedule, error) {
+	expression := strings.TrimSpace(spec)
+	if alias, ok := scheduleAliases[expression]; ok {
+		expression = alias
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q: expected 5 fields, got %d", spec, len(fields))
+	}
+
+	var s Schedule
+	var err error
+	for i, field := range []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		{&s.dow, 0, 7},
+	} {
+		if *field.bits, err = parseScheduleField(fields[i], field.min, field.max); err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", spec, err)
+		}
+	}
+	// 7 is Sunday too
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = s.dom == allDaysOfMonth
+	s.dowStar = s.dow&allDaysOfWeek == allDaysOfWeek
+	return &s, nil
+}
+
+// parseScheduleField returns the values of a field between min and max as a
+// bit set.
+func parseScheduleField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		low, high := min, max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(lowPart); err != nil {
+				return 0, fmt.Errorf("invalid value in %q", part)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(highPart); err != nil {
+					return 0, fmt.Errorf("invalid range in %q", part)
+				}
+			} else if hasStep {
+				// "5/15" starts at 5 and runs to the end of the range
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for value := low; value <= high; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first time the schedule runs after t, or the zero time if
+// it does not run in the next five years, e.g. for "0 0 30 2 *".
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,59 @@
+package smart
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// Wednesday
+	now := time.Date(2024, 6, 19, 12, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2024, 6, 19, 12, 31, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2024, 6, 19, 12, 40, 0, 0, time.UTC)},
+		{"0 3 * * 0", time.Date(2024, 6, 23, 3, 0, 0, 0, time.UTC)},
+		{"0 3 * * 7", time.Date(2024, 6, 23, 3, 0, 0, 0, time.UTC)},
+		{"15 2,14 * * 1-5", time.Date(2024, 6, 19, 14, 15, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Either the day of month or the day of week matches
+		{"0 0 1 * 5", time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC)},
+		// A step covering every day is unrestricted too
+		{"0 3 */1 * 1", time.Date(2024, 6, 24, 3, 0, 0, 0, time.UTC)},
+		{"0 3 1 * 0-7", time.Date(2024, 7, 1, 3, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 6, 23, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		schedule, err := ParseSchedule(test.spec)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q): %v", test.spec, err)
+		}
+		if next := schedule.Next(now); !next.Equal(test.expected) {
+			t.Errorf("Next of %q = %v, expected %v", test.spec, next, test.expected)
+		}
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"0 3 * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+	} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
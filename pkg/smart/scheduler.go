@@ -0,0 +1,178 @@
+package smart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/angelhvargas/dell-disk-exporter/pkg/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SelfTestScheduler starts short device self-tests on the NVMe drives on a
+// Schedule. At most Concurrency drives are tested at a time: the next drive is
+// started once a test completes, so the whole fleet is never tested at once.
+// It is a prometheus.Collector exporting the tests it started.
+type SelfTestScheduler struct {
+	// PollInterval is how often the self-test log of a drive under test is read.
+	PollInterval time.Duration
+	// Timeout is how long a test may run before its slot is given to the next
+	// drive. The test itself keeps running on the drive.
+	Timeout time.Duration
+	// SysfsRoot is the mount point of sysfs walked to discover the drives.
+	SysfsRoot string
+
+	executor    CommandExecutor
+	schedule    *Schedule
+	concurrency int
+	now         func() time.Time
+	started     *prometheus.CounterVec
+	exporter    *exporter.CollectorMetrics
+}
+
+// NewSelfTestScheduler returns a SelfTestScheduler running nvme through
+// executor on schedule, testing concurrency drives at a time, and registers it
+// with registry. It does nothing until Run is called.
+func NewSelfTestScheduler(executor CommandExecutor, registry *prometheus.Registry, schedule *Schedule, concurrency int) *SelfTestScheduler {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	s := &SelfTestScheduler{
+		PollInterval: 10 * time.Second,
+		Timeout:      10 * time.Minute,
+		SysfsRoot:    DefaultSysfsRoot,
+		executor:     executor,
+		schedule:     schedule,
+		concurrency:  concurrency,
+		now:          time.Now,
+		started: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "nvme_self_test_started_total",
+			Help: "Number of short device self-tests started by the exporter",
+		}, []string{"device"}),
+		exporter: exporter.NewCollectorMetrics("self_test"),
+	}
+	registry.MustRegister(s)
+	return s
+}
+
+// Describe implements prometheus.Collector.
+func (s *SelfTestScheduler) Describe(ch chan<- *prometheus.Desc) {
+	s.started.Describe(ch)
+	s.exporter.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (s *SelfTestScheduler) Collect(ch chan<- prometheus.Metric) {
+	s.started.Collect(ch)
+	s.exporter.Collect(ch)
+}
+
+// Run tests the drives every time the schedule fires until ctx is done.
+func (s *SelfTestScheduler) Run(ctx context.Context) {
+	for {
+		next := s.schedule.Next(s.now())
+		if next.IsZero() {
+			log.Printf("Self-test schedule never runs, stopping the scheduler")
+			return
+		}
+		timer := time.NewTimer(next.Sub(s.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := s.RunOnce(ctx); err != nil {
+			log.Printf("Error running self-tests: %v", err)
+		}
+	}
+}
+
+// RunOnce tests every NVMe drive, concurrency drives at a time, and returns
+// once every test completed or timed out. Drives already running a self-test
+// are skipped. It returns the last error met.
+func (s *SelfTestScheduler) RunOnce(ctx context.Context) error {
+	start := time.Now()
+	drives, err := GetNVMeDrives(s.SysfsRoot)
+	if err != nil {
+		s.exporter.CommandError("GetNVMeDrives", err)
+		s.exporter.Observe(start, err)
+		return err
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		lastErr error
+	)
+	slots := make(chan struct{}, s.concurrency)
+	for _, drive := range drives {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			s.exporter.Observe(start, ctx.Err())
+			return ctx.Err()
+		case slots <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(drive string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := s.testDrive(ctx, drive); err != nil {
+				log.Printf("Error running self-test on %s: %v", drive, err)
+				mu.Lock()
+				lastErr = err
+				mu.Unlock()
+			}
+		}(drive)
+	}
+	wg.Wait()
+	s.exporter.Observe(start, lastErr)
+	return lastErr
+}
+
+// testDrive starts a short self-test on drive and waits for it to complete.
+func (s *SelfTestScheduler) testDrive(ctx context.Context, drive string) error {
+	selfTestLog, err := readSelfTestLog(s.executor, drive)
+	if err != nil {
+		s.exporter.CommandError("GetSelfTestLog", err)
+		return err
+	}
+	if selfTestLog.CurrentOperation != 0 {
+		log.Printf("Self-test already running on %s, skipping", drive)
+		return nil
+	}
+
+	if _, err := s.executor.ExecuteCommand("nvme", "device-self-test", "/dev/"+drive, "-s", "1"); err != nil {
+		s.exporter.CommandError("StartSelfTest", err)
+		return err
+	}
+	s.started.WithLabelValues(drive).Inc()
+	log.Printf("Started short self-test on %s", drive)
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("self-test on %s still running after %s", drive, s.Timeout)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		selfTestLog, err := readSelfTestLog(s.executor, drive)
+		if err != nil {
+			s.exporter.CommandError("GetSelfTestLog", err)
+			return err
+		}
+		if selfTestLog.CurrentOperation == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package smart
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// selfTestExecutor simulates drives running a self-test for a few reads of
+// their self-test log, and records how many run at once.
+type selfTestExecutor struct {
+	mu         sync.Mutex
+	remaining  map[string]int
+	running    int
+	maxRunning int
+}
+
+func (e *selfTestExecutor) ExecuteCommand(name string, args ...string) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	device := args[1]
+	switch args[0] {
+	case "device-self-test":
+		e.remaining[device] = 3
+		e.running++
+		if e.running > e.maxRunning {
+			e.maxRunning = e.running
+		}
+		return []byte("Short Device self-test started\n"), nil
+	case "self-test-log":
+		operation := 0
+		if e.remaining[device] > 0 {
+			operation = 1
+			e.remaining[device]--
+			if e.remaining[device] == 0 {
+				e.running--
+			}
+		}
+		return []byte(fmt.Sprintf(`{"Current Device Self-Test Operation" : %d, "List of Valid Reports" : []}`, operation)), nil
+	default:
+		return nil, fmt.Errorf("unexpected command %s %v", name, args)
+	}
+}
+
+func TestSelfTestSchedulerConcurrency(t *testing.T) {
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = func(string) ([]string, error) {
+		return []string{"nvme0n1", "nvme1n1", "nvme2n1", "nvme3n1", "nvme4n1"}, nil
+	}
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	mockExecutor := &selfTestExecutor{remaining: make(map[string]int)}
+	schedule, err := ParseSchedule("@weekly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	scheduler := NewSelfTestScheduler(mockExecutor, registry, schedule, 2)
+	scheduler.PollInterval = time.Millisecond
+
+	if err := scheduler.RunOnce(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if mockExecutor.maxRunning != 2 {
+		t.Fatalf("Expected 2 drives tested at once, got %d", mockExecutor.maxRunning)
+	}
+
+	expected := `
+# HELP nvme_self_test_started_total Number of short device self-tests started by the exporter
+# TYPE nvme_self_test_started_total counter
+nvme_self_test_started_total{device="nvme0n1"} 1
+nvme_self_test_started_total{device="nvme1n1"} 1
+nvme_self_test_started_total{device="nvme2n1"} 1
+nvme_self_test_started_total{device="nvme3n1"} 1
+nvme_self_test_started_total{device="nvme4n1"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nvme_self_test_started_total"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestSelfTestSchedulerSkipsRunningTest(t *testing.T) {
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	mockExecutor := &CommandMockExecutor{
+		Outputs: map[string]string{
+			selfTestLogCommand: `{"Current Device Self-Test Operation" : 2, "Current Device Self-Test Completion" : 40}`,
+		},
+	}
+	schedule, err := ParseSchedule("@weekly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	scheduler := NewSelfTestScheduler(mockExecutor, registry, schedule, 1)
+
+	// device-self-test is not mocked, running it would fail
+	if err := scheduler.RunOnce(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if count := testutil.CollectAndCount(registry, "nvme_self_test_started_total"); count != 0 {
+		t.Fatalf("Expected no test started, got %d series", count)
+	}
+}
+
+func TestSelfTestSchedulerTimeout(t *testing.T) {
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	mockExecutor := &selfTestExecutor{remaining: make(map[string]int)}
+	schedule, err := ParseSchedule("@weekly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler := NewSelfTestScheduler(mockExecutor, prometheus.NewRegistry(), schedule, 1)
+	scheduler.PollInterval = 50 * time.Millisecond
+	scheduler.Timeout = 10 * time.Millisecond
+
+	err = scheduler.RunOnce(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "still running") {
+		t.Fatalf("Expected a timeout error, got %v", err)
+	}
+}
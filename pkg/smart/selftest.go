@@ -0,0 +1,152 @@
+package smart
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// selfTestUnused is the result of the unused entries of the self-test log.
+const selfTestUnused = 0xf
+
+// SelfTestLog is the device self-test log read with nvme self-test-log.
+type SelfTestLog struct {
+	// CurrentOperation is the code of the self-test in progress, 0 if none.
+	CurrentOperation int `json:"Current Device Self-Test Operation"`
+	// CurrentCompletion is the completion of the self-test in progress in percent.
+	CurrentCompletion int `json:"Current Device Self-Test Completion"`
+	// Results are the last 20 self-tests, newest first.
+	Results []SelfTestResult `json:"List of Valid Reports"`
+}
+
+// SelfTestResult is an entry of the self-test log.
+type SelfTestResult struct {
+	// Result is 0 when the test passed, 0xf for unused entries, see the NVMe
+	// Device Self-test log page for the other codes.
+	Result int `json:"Self test result"`
+	// Code is the type of the test: 1 for short, 2 for extended.
+	Code int `json:"Self test code"`
+	// PowerOnHours is the power-on hours of the controller when the test completed.
+	PowerOnHours float64 `json:"Power on hours (POH)"`
+}
+
+// selfTestType returns the type label of a self-test code.
+func selfTestType(code int) string {
+	switch code {
+	case 1:
+		return "short"
+	case 2:
+		return "extended"
+	case 0xe:
+		return "vendor"
+	default:
+		return fmt.Sprintf("0x%x", code)
+	}
+}
+
+// lastSelfTest is the most recent self-test of a type.
+type lastSelfTest struct {
+	result float64
+	// timestamp is derived from the power-on hours, zero when unknown.
+	timestamp float64
+}
+
+// selfTestState is the self-test log of a drive as exported.
+type selfTestState struct {
+	running    bool
+	completion float64
+	last       map[string]lastSelfTest
+}
+
+var (
+	selfTestLastResultDesc = prometheus.NewDesc(
+		"nvme_self_test_last_result",
+		"Result of the last device self-test of each type, 0 if it passed, see the NVMe Device Self-test log page for the other codes",
+		[]string{"device", "type"}, nil,
+	)
+	selfTestLastTimestampDesc = prometheus.NewDesc(
+		"nvme_self_test_last_timestamp_seconds",
+		"Approximate Unix timestamp of the completion of the last device self-test of each type, derived from the power-on hours",
+		[]string{"device", "type"}, nil,
+	)
+	selfTestRunningDesc = prometheus.NewDesc(
+		"nvme_self_test_running",
+		"Whether a device self-test is in progress",
+		[]string{"device"}, nil,
+	)
+	selfTestCompletionDesc = prometheus.NewDesc(
+		"nvme_self_test_completion_ratio",
+		"Completion of the device self-test in progress, from 0 to 1",
+		[]string{"device"}, nil,
+	)
+)
+
+// GetSelfTestLog returns the device self-test log of drive.
+func (m *Metrics) GetSelfTestLog(drive string) (*SelfTestLog, error) {
+	return readSelfTestLog(m.executor, drive)
+}
+
+func readSelfTestLog(executor CommandExecutor, drive string) (*SelfTestLog, error) {
+	output, err := executor.ExecuteCommand("nvme", "self-test-log", "/dev/"+drive, "--output-format", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var selfTestLog SelfTestLog
+	if err := json.Unmarshal(output, &selfTestLog); err != nil {
+		return nil, fmt.Errorf("error parsing nvme self-test-log output of %s: %w", drive, err)
+	}
+	return &selfTestLog, nil
+}
+
+// parseSelfTestLog returns the last self-test of each type. Their timestamps
+// are computed from powerOnHours, the current power-on hours of the drive, and
+// are zero when it is not known. They are precise to the hour only.
+func parseSelfTestLog(selfTestLog *SelfTestLog, now time.Time, powerOnHours float64, known bool) *selfTestState {
+	state := &selfTestState{
+		running:    selfTestLog.CurrentOperation != 0,
+		completion: float64(selfTestLog.CurrentCompletion) / 100,
+		last:       make(map[string]lastSelfTest),
+	}
+	for _, result := range selfTestLog.Results {
+		if result.Result == selfTestUnused {
+			continue
+		}
+		testType := selfTestType(result.Code)
+		if _, found := state.last[testType]; found {
+			continue
+		}
+		last := lastSelfTest{result: float64(result.Result)}
+		if known && powerOnHours >= result.PowerOnHours {
+			elapsed := time.Duration(powerOnHours-result.PowerOnHours) * time.Hour
+			last.timestamp = float64(now.Add(-elapsed).Unix())
+		}
+		state.last[testType] = last
+	}
+	return state
+}
+
+func describeSelfTests(ch chan<- *prometheus.Desc) {
+	ch <- selfTestLastResultDesc
+	ch <- selfTestLastTimestampDesc
+	ch <- selfTestRunningDesc
+	ch <- selfTestCompletionDesc
+}
+
+// collectSelfTests sends the self-test metrics of drive.
+func collectSelfTests(ch chan<- prometheus.Metric, drive string, state *selfTestState) {
+	running := 0.0
+	if state.running {
+		running = 1
+	}
+	ch <- prometheus.MustNewConstMetric(selfTestRunningDesc, prometheus.GaugeValue, running, drive)
+	ch <- prometheus.MustNewConstMetric(selfTestCompletionDesc, prometheus.GaugeValue, state.completion, drive)
+	for testType, last := range state.last {
+		ch <- prometheus.MustNewConstMetric(selfTestLastResultDesc, prometheus.GaugeValue, last.result, drive, testType)
+		if last.timestamp != 0 {
+			ch <- prometheus.MustNewConstMetric(selfTestLastTimestampDesc, prometheus.GaugeValue, last.timestamp, drive, testType)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package smart
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func readSelfTestLogFixture(t *testing.T) *SelfTestLog {
+	t.Helper()
+	var selfTestLog SelfTestLog
+	if err := json.Unmarshal([]byte(readTestdata(t, "self-test-log/nvme-cli-2.8.json")), &selfTestLog); err != nil {
+		t.Fatal(err)
+	}
+	return &selfTestLog
+}
+
+func TestParseSelfTestLog(t *testing.T) {
+	now := time.Date(2024, 6, 19, 12, 0, 0, 0, time.UTC)
+	state := parseSelfTestLog(readSelfTestLogFixture(t), now, 38313, true)
+
+	// The newest test of each type is kept, the log lists the newest first
+	expected := map[string]lastSelfTest{
+		"short":    {result: 0, timestamp: float64(now.Add(-13 * time.Hour).Unix())},
+		"extended": {result: 7, timestamp: float64(now.Add(-349 * time.Hour).Unix())},
+	}
+	if len(state.last) != len(expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, state.last)
+	}
+	for testType, last := range expected {
+		if state.last[testType] != last {
+			t.Errorf("Expected %s test %+v, got %+v", testType, last, state.last[testType])
+		}
+	}
+	if state.running {
+		t.Error("Expected no self-test in progress")
+	}
+
+	// Without the power-on hours of the drive the timestamps are unknown
+	state = parseSelfTestLog(readSelfTestLogFixture(t), now, 0, false)
+	if state.last["short"].timestamp != 0 {
+		t.Errorf("Expected no timestamp, got %v", state.last["short"].timestamp)
+	}
+}
+
+func TestCollectSelfTests(t *testing.T) {
+	mockExecutor := newNVMeMockExecutor()
+	mockExecutor.Outputs[smartLogCommand] = `{"temperature" : 301, "power_on_hours" : 38313}`
+	mockExecutor.Outputs[selfTestLogCommand] = readTestdata(t, "self-test-log/nvme-cli-2.8.json")
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	NewMetrics(mockExecutor, registry, 5*time.Minute)
+
+	expected := `
+# HELP nvme_self_test_last_result Result of the last device self-test of each type, 0 if it passed, see the NVMe Device Self-test log page for the other codes
+# TYPE nvme_self_test_last_result gauge
+nvme_self_test_last_result{device="nvme0n1",type="extended"} 7
+nvme_self_test_last_result{device="nvme0n1",type="short"} 0
+# HELP nvme_self_test_running Whether a device self-test is in progress
+# TYPE nvme_self_test_running gauge
+nvme_self_test_running{device="nvme0n1"} 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"nvme_self_test_last_result", "nvme_self_test_running"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+	if count := testutil.CollectAndCount(registry, "nvme_self_test_last_timestamp_seconds"); count != 2 {
+		t.Fatalf("Expected 2 nvme_self_test_last_timestamp_seconds series, got %d", count)
+	}
+}
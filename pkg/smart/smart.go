@@ -1,12 +1,15 @@
 package smart
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"os/exec"
-	"strings"
+	"sync"
 	"time"
 
+	"github.com/angelhvargas/dell-disk-exporter/pkg/exporter"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -16,50 +19,118 @@ type CommandExecutor interface {
 }
 
 // DefaultCommandExecutor implements CommandExecutor
-type DefaultCommandExecutor struct{}
+type DefaultCommandExecutor struct {
+	// Timeout bounds each command, no limit if zero.
+	Timeout time.Duration
+	// Paths maps command names such as "nvme" to the binary to run.
+	Paths map[string]string
+}
+
+// OutputExecutor is implemented by CommandExecutors that can return the
+// standard output of a command alone, used to read binary output that
+// warnings printed on standard error would corrupt.
+type OutputExecutor interface {
+	ExecuteOutput(name string, args ...string) ([]byte, error)
+}
 
+// ExecuteCommand returns the standard output and error of the command.
 func (e *DefaultCommandExecutor) ExecuteCommand(name string, args ...string) ([]byte, error) {
-	cmd := exec.Command(name, args...)
-	return cmd.CombinedOutput()
+	return e.run((*exec.Cmd).CombinedOutput, name, args...)
+}
+
+// ExecuteOutput implements OutputExecutor. Standard error is only kept in
+// the *exec.ExitError returned when the command fails.
+func (e *DefaultCommandExecutor) ExecuteOutput(name string, args ...string) ([]byte, error) {
+	return e.run((*exec.Cmd).Output, name, args...)
+}
+
+func (e *DefaultCommandExecutor) run(output func(*exec.Cmd) ([]byte, error), name string, args ...string) ([]byte, error) {
+	path := name
+	if p, ok := e.Paths[name]; ok {
+		path = p
+	}
+	if e.Timeout == 0 {
+		return output(exec.Command(path, args...))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.Timeout)
+	defer cancel()
+	out, err := output(exec.CommandContext(ctx, path, args...))
+	if ctx.Err() != nil {
+		return out, fmt.Errorf("%s timed out: %w", name, ctx.Err())
+	}
+	return out, err
 }
 
+// Metrics is a prometheus.Collector exporting the SMART logs of the NVMe
+// drives. Data is collected on scrape.
 type Metrics struct {
-	executor        CommandExecutor
-	smartLogMetrics *prometheus.GaugeVec
+	// MinInterval is the minimum time between two reads of the SMART logs.
+	// Scrapes within MinInterval of the previous read are served from its results.
+	MinInterval time.Duration
+	// LegacySMARTLog also exports every SMART log field as the untyped
+	// nvme_smart_log{device,metric} gauge used before the typed metrics.
+	LegacySMARTLog bool
+	// ApprovedFirmware maps drive models to their approved firmware revisions.
+	// When set, nvme_firmware_approved reports whether each drive runs one.
+	ApprovedFirmware map[string][]string
+	// SysfsRoot is the mount point of sysfs walked to discover the drives,
+	// DefaultSysfsRoot unless set.
+	SysfsRoot string
+
+	mu           sync.Mutex
+	lastUpdate   time.Time
+	executor     CommandExecutor
+	smartLogs    map[string]map[string]float64
+	temperatures map[string]map[string]float64
+	thresholds   map[string]*temperatureThresholds
+	controllers  map[string]*controllerIdentity
+	namespaces   map[string]*namespaceIdentity
+	selfTests    map[string]*selfTestState
+	// sysfsControllers are the controllers found by the last discovery.
+	sysfsControllers []Controller
+	// errorLogs, firmwareLogs, vendorLogs and unsupportedLogs hold the logs of
+	// each controller, see namespaceController. vendorLogs holds the metrics
+	// of the vendor logs by log name.
+	errorLogs       map[string]*errorLogState
+	firmwareLogs    map[string]*FirmwareLog
+	vendorLogs      map[string]map[string][]VendorMetric
+	unsupportedLogs map[string]map[string]bool
 	nvmePresence    *prometheus.GaugeVec
+	exporter        *exporter.CollectorMetrics
+	breaker         *exporter.Breaker
+	knownDrives     map[string]bool
 	absentDrives    map[string]time.Time
 	absentDuration  time.Duration
 }
 
-// Exported for testing
-var GetNVMeDrives = func() ([]string, error) {
-	cmd := exec.Command("lsblk", "-d", "-n", "-o", "NAME,TYPE")
-	output, err := cmd.CombinedOutput()
+// GetNVMeDrives returns the NVMe namespace block devices found in the sysfs
+// tree mounted at sysfsRoot, e.g. "nvme0n1". Multipath namespaces are listed
+// once. Exported for testing.
+var GetNVMeDrives = func(sysfsRoot string) ([]string, error) {
+	topology, err := Discover(sysfsRoot)
 	if err != nil {
 		return nil, err
 	}
 
-	var drives []string
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "nvme") {
-			parts := strings.Fields(line)
-			if len(parts) > 0 {
-				drives = append(drives, parts[0])
-			}
-		}
+	drives := make([]string, 0, len(topology.Namespaces))
+	for _, namespace := range topology.Namespaces {
+		drives = append(drives, namespace.Name)
 	}
 	return drives, nil
 }
 
+// GetNVMeControllers returns the NVMe controllers found in the sysfs tree
+// mounted at sysfsRoot. Exported for testing.
+var GetNVMeControllers = func(sysfsRoot string) ([]Controller, error) {
+	topology, err := Discover(sysfsRoot)
+	if err != nil {
+		return nil, err
+	}
+	return topology.Controllers, nil
+}
+
 func NewMetrics(executor CommandExecutor, registry *prometheus.Registry, absentDuration time.Duration) *Metrics {
-	smartLogMetrics := prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "nvme_smart_log",
-			Help: "SMART log metrics for NVMe devices",
-		},
-		[]string{"device", "metric"},
-	)
 	nvmePresence := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "nvme_presence",
@@ -67,15 +138,101 @@ func NewMetrics(executor CommandExecutor, registry *prometheus.Registry, absentD
 		},
 		[]string{"device"},
 	)
-	registry.MustRegister(smartLogMetrics)
-	registry.MustRegister(nvmePresence)
-	return &Metrics{
+	m := &Metrics{
+		SysfsRoot:       DefaultSysfsRoot,
 		executor:        executor,
-		smartLogMetrics: smartLogMetrics,
+		smartLogs:       make(map[string]map[string]float64),
+		temperatures:    make(map[string]map[string]float64),
+		thresholds:      make(map[string]*temperatureThresholds),
+		controllers:     make(map[string]*controllerIdentity),
+		namespaces:      make(map[string]*namespaceIdentity),
+		errorLogs:       make(map[string]*errorLogState),
+		selfTests:       make(map[string]*selfTestState),
+		firmwareLogs:    make(map[string]*FirmwareLog),
+		vendorLogs:      make(map[string]map[string][]VendorMetric),
+		unsupportedLogs: make(map[string]map[string]bool),
 		nvmePresence:    nvmePresence,
+		exporter:        exporter.NewCollectorMetrics("smart"),
+		breaker:         exporter.NewBreaker("smart", exporter.DefaultBackoff),
+		knownDrives:     make(map[string]bool),
 		absentDrives:    make(map[string]time.Time),
 		absentDuration:  absentDuration,
 	}
+	registry.MustRegister(m)
+	return m
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	describeSmartLog(ch)
+	describeTemperatures(ch)
+	describeIdentity(ch)
+	ch <- controllerInfoDesc
+	ch <- errorLogEntriesDesc
+	describeSelfTests(ch)
+	describeFirmware(ch)
+	describeVendorLogs(ch)
+	m.nvmePresence.Describe(ch)
+	m.exporter.Describe(ch)
+	m.breaker.Describe(ch)
+}
+
+// Collect implements prometheus.Collector. It reads the SMART logs unless the
+// previous read is younger than MinInterval or the breaker is backing off
+// after failures, in which case the previous results are served.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if (m.lastUpdate.IsZero() || time.Since(m.lastUpdate) >= m.MinInterval) && m.breaker.Allow() {
+		err := m.update()
+		if err != nil {
+			log.Printf("Error collecting NVMe metrics: %v", err)
+		}
+		m.breaker.Record(err)
+	}
+	for drive, values := range m.smartLogs {
+		collectSmartLog(ch, drive, values, m.LegacySMARTLog)
+	}
+	for drive, temperatures := range m.temperatures {
+		collectTemperatures(ch, drive, temperatures, m.thresholds[drive])
+	}
+	for drive, identity := range m.controllers {
+		collectControllerIdentity(ch, drive, identity)
+	}
+	for drive, identity := range m.namespaces {
+		collectNamespaceIdentity(ch, drive, identity)
+	}
+	for _, controller := range m.sysfsControllers {
+		collectControllerInfo(ch, controller)
+	}
+	for drive, state := range m.selfTests {
+		collectSelfTests(ch, drive, state)
+	}
+	for drive := range m.knownDrives {
+		controllerName := namespaceController(drive)
+		if state, found := m.errorLogs[controllerName]; found {
+			collectErrorLog(ch, drive, state)
+		}
+		if firmwareLog, found := m.firmwareLogs[controllerName]; found {
+			collectFirmwareLog(ch, drive, firmwareLog)
+		}
+		if values, found := m.vendorLogs[controllerName]; found {
+			collectVendorLogs(ch, drive, values)
+		}
+	}
+	if m.ApprovedFirmware != nil {
+		for drive, identity := range m.controllers {
+			firmware := identity.firmware
+			if firmwareLog, found := m.firmwareLogs[namespaceController(drive)]; found && firmwareLog.ActiveRevision() != "" {
+				firmware = firmwareLog.ActiveRevision()
+			}
+			collectFirmwareApproved(ch, drive, firmwareApproved(m.ApprovedFirmware, identity.model, firmware))
+		}
+	}
+	m.nvmePresence.Collect(ch)
+	m.exporter.Collect(ch)
+	m.breaker.Collect(ch)
 }
 
 func (m *Metrics) GetSMARTLog(drive string) (map[string]interface{}, error) {
@@ -92,67 +249,192 @@ func (m *Metrics) GetSMARTLog(drive string) (map[string]interface{}, error) {
 	return smartLog, nil
 }
 
-func parseNvmeSmartLogText(output string) (map[string]interface{}, error) {
-	smartLog := make(map[string]interface{})
-	lines := strings.Split(output, "\n")
+// GetIDCtrl returns the identify controller data of the controller behind drive.
+func (m *Metrics) GetIDCtrl(drive string) (map[string]interface{}, error) {
+	output, err := m.executor.ExecuteCommand("nvme", "id-ctrl", "/dev/"+drive, "--output-format", "json")
+	if err != nil {
+		return nil, err
+	}
 
-	for _, line := range lines {
-		if strings.Contains(line, ":") {
-			parts := strings.Split(line, ":")
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			smartLog[key] = value
+	var idCtrl map[string]interface{}
+	if err := json.Unmarshal(output, &idCtrl); err != nil {
+		idCtrl = make(map[string]interface{})
+		for key, value := range parseNvmeText(string(output)) {
+			idCtrl[key] = value
 		}
 	}
 
-	return smartLog, nil
+	return idCtrl, nil
+}
+
+// Update reads the SMART logs once. It returns an error when the drives cannot
+// be listed. Failures to read a drive are only counted in command_errors_total,
+// so one faulty drive does not back off the others.
+func (m *Metrics) Update() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.update()
 }
 
-func (m *Metrics) UpdateMetrics() {
-	for {
-		drives, err := GetNVMeDrives()
+// update refreshes the series of every drive. Series of a drive that
+// disappears are kept with nvme_presence set to 0 for absentDuration.
+func (m *Metrics) update() error {
+	start := time.Now()
+	m.lastUpdate = start
+	drives, err := GetNVMeDrives(m.SysfsRoot)
+	if err != nil {
+		m.exporter.CommandError("GetNVMeDrives", err)
+		m.exporter.Observe(start, err)
+		return err
+	}
+	// The controllers are listed as found, with every path of multipath
+	// namespaces, and have no grace period.
+	controllers, err := GetNVMeControllers(m.SysfsRoot)
+	if err != nil {
+		log.Printf("Error listing NVMe controllers: %v", err)
+		m.exporter.CommandError("GetNVMeControllers", err)
+	} else {
+		m.sysfsControllers = controllers
+	}
+
+	currentDrives := make(map[string]bool)
+	// controllerRead holds the controllers whose logs were read by this update.
+	controllerRead := make(map[string]bool)
+	for _, drive := range drives {
+		currentDrives[drive] = true
+		m.knownDrives[drive] = true
+		delete(m.absentDrives, drive)
+		m.nvmePresence.WithLabelValues(drive).Set(1)
+
+		logData, err := m.GetSMARTLog(drive)
 		if err != nil {
-			log.Printf("Error detecting NVMe drives: %v", err)
-			return
+			log.Printf("Error getting SMART log for %s: %v", drive, err)
+			m.exporter.CommandError("GetSMARTLog", err)
+			continue
 		}
-
-		currentDrives := make(map[string]bool)
-		for _, drive := range drives {
-			currentDrives[drive] = true
-			logData, err := m.GetSMARTLog(drive)
-			if err != nil {
-				log.Printf("Error getting SMART log for %s: %v", drive, err)
+		log.Printf("SMART Log for %s: %v", drive, logData)
+		values := make(map[string]float64)
+		for key, value := range logData {
+			floatValue, ok := value.(float64)
+			if !ok {
 				continue
 			}
-			log.Printf("SMART Log for %s: %v", drive, logData)
-			for key, value := range logData {
-				floatValue, ok := value.(float64)
-				if !ok {
-					continue
-				}
-				m.smartLogMetrics.WithLabelValues(drive, key).Set(floatValue)
+			values[key] = floatValue
+		}
+		m.smartLogs[drive] = values
+		m.temperatures[drive] = parseTemperatures(logData)
+
+		// The firmware, error and vendor logs are controller logs, read once
+		// for all its namespaces.
+		controllerName := namespaceController(drive)
+		readController := !controllerRead[controllerName]
+		controllerRead[controllerName] = true
+		if readController {
+			firmwareLog, err := m.GetFirmwareLog(drive)
+			if err != nil {
+				log.Printf("Error getting firmware log for %s: %v", drive, err)
+				m.exporter.CommandError("GetFirmwareLog", err)
+				delete(m.firmwareLogs, controllerName)
+			} else {
+				m.firmwareLogs[controllerName] = firmwareLog
 			}
-			m.nvmePresence.WithLabelValues(drive).Set(1)
-			delete(m.absentDrives, drive)
 		}
+		firmwareLog := m.firmwareLogs[controllerName]
 
-		for drive, timestamp := range m.absentDrives {
-			if !currentDrives[drive] {
-				if time.Since(timestamp) > m.absentDuration {
-					m.nvmePresence.DeleteLabelValues(drive)
-					m.smartLogMetrics.DeleteLabelValues(drive)
-				} else {
-					m.nvmePresence.WithLabelValues(drive).Set(0)
-				}
+		// The controller identity and thresholds only change with a firmware
+		// update. They are read once per drive, and again once a new firmware
+		// is activated.
+		controller, found := m.controllers[drive]
+		if found && firmwareLog != nil && firmwareLog.ActiveRevision() != "" && firmwareLog.ActiveRevision() != controller.firmware {
+			found = false
+		}
+		if !found {
+			idCtrl, err := m.GetIDCtrl(drive)
+			if err != nil {
+				log.Printf("Error getting controller identity for %s: %v", drive, err)
+				m.exporter.CommandError("GetIDCtrl", err)
+			} else {
+				thresholds := parseThresholds(idCtrl)
+				m.thresholds[drive] = &thresholds
+				identity := parseControllerIdentity(idCtrl)
+				m.controllers[drive] = &identity
 			}
 		}
 
-		for drive := range currentDrives {
-			if _, found := m.absentDrives[drive]; !found {
-				m.absentDrives[drive] = time.Now()
+		// Vendor logs are selected by the PCI vendor ID of the controller.
+		if controller, found := m.controllers[drive]; found && readController {
+			m.vendorLogs[controllerName] = m.readVendorLogs(controllerName, drive, controller.vendorID)
+		}
+
+		// The namespace utilization changes with the data written.
+		idNS, err := m.GetIDNS(drive)
+		if err != nil {
+			log.Printf("Error getting namespace identity for %s: %v", drive, err)
+			m.exporter.CommandError("GetIDNS", err)
+		} else if identity, ok := parseNamespaceIdentity(idNS); ok {
+			m.namespaces[drive] = &identity
+		}
+
+		if readController {
+			errorLog, err := m.GetErrorLog(drive)
+			if err != nil {
+				log.Printf("Error getting error log for %s: %v", drive, err)
+				m.exporter.CommandError("GetErrorLog", err)
+			} else {
+				m.recordErrorLog(controllerName, errorLog)
 			}
 		}
 
-		time.Sleep(30 * time.Second) // Adjust the interval as needed
+		selfTestLog, err := m.GetSelfTestLog(drive)
+		if err != nil {
+			log.Printf("Error getting self-test log for %s: %v", drive, err)
+			m.exporter.CommandError("GetSelfTestLog", err)
+		} else {
+			powerOnHours, known := values["power_on_hours"]
+			m.selfTests[drive] = parseSelfTestLog(selfTestLog, start, powerOnHours, known)
+		}
+	}
+
+	// removedControllers holds the controllers of the drives removed below.
+	removedControllers := make(map[string]bool)
+	for drive := range m.knownDrives {
+		if currentDrives[drive] {
+			continue
+		}
+		since, found := m.absentDrives[drive]
+		if !found {
+			since = time.Now()
+			m.absentDrives[drive] = since
+		}
+		if time.Since(since) > m.absentDuration {
+			m.nvmePresence.DeleteLabelValues(drive)
+			delete(m.smartLogs, drive)
+			delete(m.temperatures, drive)
+			delete(m.thresholds, drive)
+			delete(m.controllers, drive)
+			delete(m.namespaces, drive)
+			delete(m.selfTests, drive)
+			delete(m.absentDrives, drive)
+			delete(m.knownDrives, drive)
+			removedControllers[namespaceController(drive)] = true
+		} else {
+			m.nvmePresence.WithLabelValues(drive).Set(0)
+		}
+	}
+
+	// Drop the logs of the controllers whose drives were all removed.
+	knownControllers := make(map[string]bool)
+	for drive := range m.knownDrives {
+		knownControllers[namespaceController(drive)] = true
+	}
+	for controllerName := range removedControllers {
+		if !knownControllers[controllerName] {
+			delete(m.firmwareLogs, controllerName)
+			delete(m.errorLogs, controllerName)
+			delete(m.vendorLogs, controllerName)
+			delete(m.unsupportedLogs, controllerName)
+		}
 	}
+	m.exporter.Observe(start, nil)
+	return nil
 }
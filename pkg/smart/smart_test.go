@@ -2,6 +2,10 @@ package smart
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -23,13 +27,69 @@ func (e *MockCommandExecutor) ExecuteCommand(name string, args ...string) ([]byt
 	return []byte(e.MockOutput), nil
 }
 
+// CommandMockExecutor returns canned output keyed by the full command line,
+// e.g. "nvme smart-log /dev/nvme0n1 --output-format json".
+type CommandMockExecutor struct {
+	Outputs map[string]string
+	Errors  map[string]error
+}
+
+func (e *CommandMockExecutor) ExecuteCommand(name string, args ...string) ([]byte, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	if err, ok := e.Errors[command]; ok {
+		return nil, err
+	}
+	output, ok := e.Outputs[command]
+	if !ok {
+		return nil, fmt.Errorf("unexpected command %q", command)
+	}
+	return []byte(output), nil
+}
+
 // mockGetNVMeDrives simulates the function to detect NVMe drives for testing
-var mockGetNVMeDrives = func() ([]string, error) {
+var mockGetNVMeDrives = func(string) ([]string, error) {
 	return []string{"nvme0n1"}, nil
 }
 
+// Commands run by Metrics to read nvme0n1.
+const (
+	smartLogCommand    = "nvme smart-log /dev/nvme0n1 --output-format json"
+	firmwareLogCommand = "nvme fw-log /dev/nvme0n1 --output-format json"
+	idCtrlCommand      = "nvme id-ctrl /dev/nvme0n1 --output-format json"
+	idNSCommand        = "nvme id-ns /dev/nvme0n1 --output-format json"
+	errorLogCommand    = "nvme error-log /dev/nvme0n1 --output-format json"
+	selfTestLogCommand = "nvme self-test-log /dev/nvme0n1 --output-format json"
+)
+
+// newNVMeMockExecutor returns a CommandMockExecutor answering every command
+// run by Metrics for nvme0n1 with minimal output. Tests override the
+// commands they exercise.
+func newNVMeMockExecutor() *CommandMockExecutor {
+	return &CommandMockExecutor{
+		Outputs: map[string]string{
+			smartLogCommand:    `{"temperature" : 301}`,
+			firmwareLogCommand: `{"nvme0n1" : {"Active Firmware Slot (afi)" : 1}}`,
+			idCtrlCommand:      `{}`,
+			idNSCommand:        `{}`,
+			errorLogCommand:    `{"errors" : []}`,
+			selfTestLogCommand: `{}`,
+		},
+		Errors: map[string]error{},
+	}
+}
+
+// readTestdata returns the content of the file at path under testdata.
+func readTestdata(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
 // mockGetNVMeDrivesAbsent simulates the function to detect NVMe drives, but simulates the drive becoming absent
-var mockGetNVMeDrivesAbsent = func() ([]string, error) {
+var mockGetNVMeDrivesAbsent = func(string) ([]string, error) {
 	return []string{}, nil
 }
 
@@ -129,11 +189,7 @@ func TestUpdateMetrics(t *testing.T) {
 
 	registry := prometheus.NewRegistry()
 	metrics := NewMetrics(mockExecutor, registry, 5*time.Minute)
-
-	go metrics.UpdateMetrics()
-
-	// Allow some time for metrics to be updated
-	time.Sleep(1 * time.Second)
+	metrics.LegacySMARTLog = true
 
 	// Test SMART log metrics
 	expectedMetrics := `
@@ -172,11 +228,6 @@ nvme_smart_log{device="nvme0n1",metric="warning_temp_time"} 0
 	// Simulate the drive becoming absent
 	GetNVMeDrives = mockGetNVMeDrivesAbsent
 
-	go metrics.UpdateMetrics()
-
-	// Allow some time for metrics to be updated
-	time.Sleep(1 * time.Second)
-
 	// Test NVMe presence metric
 	expectedPresenceMetrics := `
 # HELP nvme_presence Presence of NVMe devices
@@ -221,3 +272,222 @@ nvme_smart_log{device="nvme0n1",metric="warning_temp_time"} 0
 		t.Fatalf("unexpected collecting result:\n%s", err)
 	}
 }
+
+func TestCollectTypedMetrics(t *testing.T) {
+	mockExecutor := &MockCommandExecutor{
+		MockOutput: `{
+  "critical_warning" : 0,
+  "temperature" : 301,
+  "avail_spare" : 100,
+  "percent_used" : 15,
+  "data_units_written" : 1474968593,
+  "controller_busy_time" : 1974546,
+  "power_on_hours" : 38313,
+  "media_errors" : 0,
+  "thm_temp1_trans_count" : 2,
+  "thm_temp2_trans_count" : 0,
+  "temperature_sensor_1" : 306
+}`,
+	}
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	NewMetrics(mockExecutor, registry, 5*time.Minute)
+
+	expected := `
+# HELP nvme_available_spare_ratio Remaining spare capacity, from 0 to 1
+# TYPE nvme_available_spare_ratio gauge
+nvme_available_spare_ratio{device="nvme0n1"} 1
+# HELP nvme_controller_busy_time_seconds_total Time the controller was busy with I/O commands in seconds
+# TYPE nvme_controller_busy_time_seconds_total counter
+nvme_controller_busy_time_seconds_total{device="nvme0n1"} 1.1847276e+08
+# HELP nvme_critical_warning Critical warning bit field of the SMART log, 0 when no warning is raised
+# TYPE nvme_critical_warning gauge
+nvme_critical_warning{device="nvme0n1"} 0
+# HELP nvme_data_units_written_bytes_total Bytes written by the host
+# TYPE nvme_data_units_written_bytes_total counter
+nvme_data_units_written_bytes_total{device="nvme0n1"} 7.55183919616e+14
+# HELP nvme_media_errors_total Number of unrecovered data integrity errors
+# TYPE nvme_media_errors_total counter
+nvme_media_errors_total{device="nvme0n1"} 0
+# HELP nvme_percentage_used_ratio Vendor estimate of the used endurance, may exceed 1
+# TYPE nvme_percentage_used_ratio gauge
+nvme_percentage_used_ratio{device="nvme0n1"} 0.15
+# HELP nvme_power_on_hours_total Number of power-on hours
+# TYPE nvme_power_on_hours_total counter
+nvme_power_on_hours_total{device="nvme0n1"} 38313
+# HELP nvme_temperature_celsius Temperature of the controller in degrees Celsius, by sensor (composite or the sensor number)
+# TYPE nvme_temperature_celsius gauge
+nvme_temperature_celsius{device="nvme0n1",sensor="1"} 33
+nvme_temperature_celsius{device="nvme0n1",sensor="composite"} 28
+# HELP nvme_thermal_management_transitions_total Number of times the controller entered a thermal management temperature level
+# TYPE nvme_thermal_management_transitions_total counter
+nvme_thermal_management_transitions_total{device="nvme0n1",level="1"} 2
+nvme_thermal_management_transitions_total{device="nvme0n1",level="2"} 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"nvme_available_spare_ratio", "nvme_controller_busy_time_seconds_total", "nvme_critical_warning",
+		"nvme_data_units_written_bytes_total", "nvme_media_errors_total", "nvme_percentage_used_ratio",
+		"nvme_power_on_hours_total", "nvme_temperature_celsius", "nvme_thermal_management_transitions_total"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+
+	// The legacy gauge is only exported when enabled
+	if count := testutil.CollectAndCount(registry, "nvme_smart_log"); count != 0 {
+		t.Fatalf("Expected no nvme_smart_log series, got %d", count)
+	}
+}
+
+func TestCollectAbsentDriveRemoved(t *testing.T) {
+	mockExecutor := &MockCommandExecutor{MockOutput: `{"temperature" : 301}`}
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(mockExecutor, registry, 100*time.Millisecond)
+
+	if count := testutil.CollectAndCount(metrics, "nvme_temperature_celsius"); count != 1 {
+		t.Fatalf("Expected 1 nvme_temperature_celsius series, got %d", count)
+	}
+
+	GetNVMeDrives = mockGetNVMeDrivesAbsent
+	if count := testutil.CollectAndCount(metrics, "nvme_temperature_celsius"); count != 1 {
+		t.Fatalf("Expected the absent drive to be kept during the grace period, got %d series", count)
+	}
+
+	// Once the grace period is over the series are removed
+	time.Sleep(200 * time.Millisecond)
+	if count := testutil.CollectAndCount(metrics, "nvme_temperature_celsius", "nvme_presence"); count != 0 {
+		t.Fatalf("Expected no series, got %d", count)
+	}
+}
+
+func TestCollectSelfMonitoring(t *testing.T) {
+	mockExecutor := &MockCommandExecutor{MockError: errors.New("nvme: exit status 1")}
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	NewMetrics(mockExecutor, registry, 5*time.Minute)
+
+	expected := `
+# HELP dell_disk_exporter_collector_success Whether the last collection succeeded
+# TYPE dell_disk_exporter_collector_success gauge
+dell_disk_exporter_collector_success{collector="smart"} 1
+# HELP dell_disk_exporter_command_errors_total Number of failed commands by reason (timeout, not_found, exit_status, error)
+# TYPE dell_disk_exporter_command_errors_total counter
+dell_disk_exporter_command_errors_total{collector="smart",command="GetSMARTLog",reason="error"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"dell_disk_exporter_collector_success", "dell_disk_exporter_command_errors_total"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestCollectDriveFailureKeepsBreakerClosed(t *testing.T) {
+	mockExecutor := newNVMeMockExecutor()
+	mockExecutor.Errors["nvme smart-log /dev/nvme1n1 --output-format json"] = errors.New("nvme: exit status 1")
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = func(string) ([]string, error) { return []string{"nvme0n1", "nvme1n1"}, nil }
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(mockExecutor, registry, 5*time.Minute)
+
+	if err := metrics.Update(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The failing drive does not back off the healthy one, which keeps refreshing
+	for _, temperature := range []string{"301", "311"} {
+		mockExecutor.Outputs[smartLogCommand] = `{"temperature" : ` + temperature + `}`
+		if _, err := registry.Gather(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	mockExecutor.Outputs[smartLogCommand] = `{"temperature" : 321}`
+
+	expected := `
+# HELP dell_disk_exporter_collector_breaker_state State of the collector circuit breaker (0=Closed, 1=Open, 2=HalfOpen)
+# TYPE dell_disk_exporter_collector_breaker_state gauge
+dell_disk_exporter_collector_breaker_state{collector="smart"} 0
+# HELP dell_disk_exporter_collector_consecutive_failures Number of consecutive failed collections
+# TYPE dell_disk_exporter_collector_consecutive_failures gauge
+dell_disk_exporter_collector_consecutive_failures{collector="smart"} 0
+# HELP dell_disk_exporter_command_errors_total Number of failed commands by reason (timeout, not_found, exit_status, error)
+# TYPE dell_disk_exporter_command_errors_total counter
+dell_disk_exporter_command_errors_total{collector="smart",command="GetSMARTLog",reason="error"} 4
+# HELP nvme_temperature_celsius Temperature of the controller in degrees Celsius, by sensor (composite or the sensor number)
+# TYPE nvme_temperature_celsius gauge
+nvme_temperature_celsius{device="nvme0n1",sensor="composite"} 48
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "dell_disk_exporter_collector_breaker_state",
+		"dell_disk_exporter_collector_consecutive_failures", "dell_disk_exporter_command_errors_total", "nvme_temperature_celsius"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestCollectBackoffOnDiscoveryFailure(t *testing.T) {
+	calls := 0
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = func(string) ([]string, error) {
+		calls++
+		return nil, errors.New("open /sys/class/nvme: permission denied")
+	}
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	NewMetrics(&MockCommandExecutor{}, registry, 5*time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := registry.Gather(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	// Discovery is retried after the backoff instead of on every scrape or never
+	if calls != 1 {
+		t.Fatalf("Expected discovery to run once, got %d calls", calls)
+	}
+
+	expected := `
+# HELP dell_disk_exporter_collector_consecutive_failures Number of consecutive failed collections
+# TYPE dell_disk_exporter_collector_consecutive_failures gauge
+dell_disk_exporter_collector_consecutive_failures{collector="smart"} 1
+# HELP dell_disk_exporter_collector_success Whether the last collection succeeded
+# TYPE dell_disk_exporter_collector_success gauge
+dell_disk_exporter_collector_success{collector="smart"} 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"dell_disk_exporter_collector_consecutive_failures", "dell_disk_exporter_collector_success"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestDefaultCommandExecutorOutput(t *testing.T) {
+	executor := &DefaultCommandExecutor{Paths: map[string]string{"nvme": "sh"}}
+	script := "printf raw; echo warning >&2"
+
+	output, err := executor.ExecuteOutput("nvme", "-c", script)
+	if err != nil || string(output) != "raw" {
+		t.Fatalf("Expected the standard output alone, got %q, %v", output, err)
+	}
+	output, err = executor.ExecuteCommand("nvme", "-c", script)
+	if err != nil || string(output) != "rawwarning\n" {
+		t.Fatalf("Expected the combined output, got %q, %v", output, err)
+	}
+
+	// Standard error is kept in the exit error
+	_, err = executor.ExecuteOutput("nvme", "-c", "echo failed >&2; exit 1")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || string(exitErr.Stderr) != "failed\n" {
+		t.Fatalf("Expected an exit error with the standard error, got %v", err)
+	}
+}
@@ -0,0 +1,443 @@
+package smart
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angelhvargas/dell-disk-exporter/pkg/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// smartctl exit status bits meaning that no data could be read, see smartctl(8).
+// The other bits report the health of the drive and come with a full output.
+const smartctlFatalExitStatus = 1<<0 | 1<<1
+
+// SmartctlDevice is a drive listed by smartctl --scan.
+type SmartctlDevice struct {
+	Name     string `json:"name"`
+	InfoName string `json:"info_name"`
+	// Type is the smartctl device type passed with -d, e.g. "sat" or "scsi".
+	Type     string `json:"type"`
+	Protocol string `json:"protocol"`
+}
+
+// SmartctlInfo is the subset of the smartctl --json -a output exported as metrics.
+type SmartctlInfo struct {
+	Smartctl struct {
+		ExitStatus int `json:"exit_status"`
+		Messages   []struct {
+			String   string `json:"string"`
+			Severity string `json:"severity"`
+		} `json:"messages"`
+	} `json:"smartctl"`
+	Device          SmartctlDevice `json:"device"`
+	ModelFamily     string         `json:"model_family"`
+	ModelName       string         `json:"model_name"`
+	SerialNumber    string         `json:"serial_number"`
+	FirmwareVersion string         `json:"firmware_version"`
+	ScsiVendor      string         `json:"scsi_vendor"`
+	ScsiProduct     string         `json:"scsi_product"`
+	ScsiRevision    string         `json:"scsi_revision"`
+	SmartStatus     *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature struct {
+		Current *float64 `json:"current"`
+	} `json:"temperature"`
+	PowerOnTime struct {
+		Hours *float64 `json:"hours"`
+	} `json:"power_on_time"`
+	ATASmartAttributes struct {
+		Table []ATAAttribute `json:"table"`
+	} `json:"ata_smart_attributes"`
+	ScsiGrownDefectList *float64 `json:"scsi_grown_defect_list"`
+	// ScsiErrorCounterLog is keyed by operation: read, write or verify.
+	ScsiErrorCounterLog map[string]ScsiErrorCounter `json:"scsi_error_counter_log"`
+
+	// pdisk is the racadm FQDD of the drive, if it is known to the controller.
+	pdisk string
+}
+
+// ATAAttribute is a row of the ATA SMART attribute table.
+type ATAAttribute struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Value  float64 `json:"value"`
+	Worst  float64 `json:"worst"`
+	Thresh float64 `json:"thresh"`
+	Raw    struct {
+		Value  float64 `json:"value"`
+		String string  `json:"string"`
+	} `json:"raw"`
+}
+
+// ScsiErrorCounter is an entry of the SCSI error counter log.
+type ScsiErrorCounter struct {
+	ErrorsCorrectedByECCFast         float64 `json:"errors_corrected_by_eccfast"`
+	ErrorsCorrectedByECCDelayed      float64 `json:"errors_corrected_by_eccdelayed"`
+	ErrorsCorrectedByRereadsRewrites float64 `json:"errors_corrected_by_rereads_rewrites"`
+	TotalErrorsCorrected             float64 `json:"total_errors_corrected"`
+	CorrectionAlgorithmInvocations   float64 `json:"correction_algorithm_invocations"`
+	GigabytesProcessed               string  `json:"gigabytes_processed"`
+	TotalUncorrectedErrors           float64 `json:"total_uncorrected_errors"`
+}
+
+// model returns the model of ATA drives, or the vendor and product of SCSI drives.
+func (i *SmartctlInfo) model() string {
+	if i.ModelName != "" {
+		return i.ModelName
+	}
+	return strings.TrimSpace(i.ScsiVendor + " " + i.ScsiProduct)
+}
+
+func (i *SmartctlInfo) firmware() string {
+	if i.FirmwareVersion != "" {
+		return i.FirmwareVersion
+	}
+	return i.ScsiRevision
+}
+
+var (
+	smartctlDeviceLabels = []string{"device"}
+
+	smartctlInfoDesc = prometheus.NewDesc(
+		"smartctl_device_info",
+		"Information about the drive read with smartctl, always 1",
+		[]string{"device", "type", "protocol", "model_family", "model", "serial", "firmware", "pdisk"}, nil,
+	)
+	smartctlPassedDesc = prometheus.NewDesc(
+		"smartctl_device_smart_passed",
+		"Whether the drive passed its SMART overall-health self-assessment",
+		smartctlDeviceLabels, nil,
+	)
+	smartctlTemperatureDesc = prometheus.NewDesc(
+		"smartctl_device_temperature_celsius",
+		"Current temperature of the drive in degrees Celsius",
+		smartctlDeviceLabels, nil,
+	)
+	smartctlPowerOnHoursDesc = prometheus.NewDesc(
+		"smartctl_device_power_on_hours_total",
+		"Number of power-on hours of the drive",
+		smartctlDeviceLabels, nil,
+	)
+	smartctlExitStatusDesc = prometheus.NewDesc(
+		"smartctl_device_exit_status",
+		"Exit status bit mask of the last smartctl run for the drive, see smartctl(8)",
+		smartctlDeviceLabels, nil,
+	)
+	ataAttributeLabels = []string{"device", "attribute_id", "attribute_name"}
+
+	ataAttributeValueDesc = prometheus.NewDesc(
+		"smartctl_ata_attribute_value",
+		"Normalized value of the ATA SMART attribute",
+		ataAttributeLabels, nil,
+	)
+	ataAttributeWorstDesc = prometheus.NewDesc(
+		"smartctl_ata_attribute_worst",
+		"Worst normalized value of the ATA SMART attribute",
+		ataAttributeLabels, nil,
+	)
+	ataAttributeThresholdDesc = prometheus.NewDesc(
+		"smartctl_ata_attribute_threshold",
+		"Failure threshold of the normalized value of the ATA SMART attribute",
+		ataAttributeLabels, nil,
+	)
+	ataAttributeRawDesc = prometheus.NewDesc(
+		"smartctl_ata_attribute_raw_value",
+		"Raw value of the ATA SMART attribute, vendor specific",
+		ataAttributeLabels, nil,
+	)
+	scsiErrorLabels = []string{"device", "operation"}
+
+	scsiErrorsCorrectedDesc = prometheus.NewDesc(
+		"smartctl_scsi_errors_corrected_total",
+		"Number of errors corrected by the SAS drive, by operation (read, write, verify)",
+		scsiErrorLabels, nil,
+	)
+	scsiErrorsUncorrectedDesc = prometheus.NewDesc(
+		"smartctl_scsi_errors_uncorrected_total",
+		"Number of uncorrected errors of the SAS drive, by operation (read, write, verify)",
+		scsiErrorLabels, nil,
+	)
+	scsiProcessedBytesDesc = prometheus.NewDesc(
+		"smartctl_scsi_processed_bytes_total",
+		"Bytes processed by the SAS drive, by operation (read, write, verify)",
+		scsiErrorLabels, nil,
+	)
+	scsiGrownDefectsDesc = prometheus.NewDesc(
+		"smartctl_scsi_grown_defects",
+		"Number of entries in the grown defect list of the SAS drive",
+		smartctlDeviceLabels, nil,
+	)
+)
+
+// SmartctlMetrics is a prometheus.Collector exporting the SMART data of SATA
+// and SAS drives read with smartctl. NVMe drives are left to Metrics. Data is
+// collected on scrape; drives no longer listed disappear on the next collection.
+type SmartctlMetrics struct {
+	// MinInterval is the minimum time between two smartctl runs.
+	// Scrapes within MinInterval of the previous run are served from its results.
+	MinInterval time.Duration
+	// MegaRAID lists the drives with smartctl --scan-open, which also finds the
+	// physical drives behind PERC/MegaRAID controllers (-d megaraid,N).
+	MegaRAID bool
+	// PDisks returns the racadm FQDD of the controller physical disks keyed by
+	// serial number, see idrac.PDiskSerials. When set, drives are labelled with
+	// their pdisk. It is called again only when an unknown drive shows up.
+	PDisks func() (map[string]string, error)
+
+	mu         sync.Mutex
+	lastUpdate time.Time
+	executor   CommandExecutor
+	devices    map[string]*SmartctlInfo
+	pdisks     map[string]string
+	serials    map[string]bool
+	exporter   *exporter.CollectorMetrics
+	breaker    *exporter.Breaker
+}
+
+// NewSmartctlMetrics returns a SmartctlMetrics running smartctl through executor
+// and registers it with registry.
+func NewSmartctlMetrics(executor CommandExecutor, registry *prometheus.Registry) *SmartctlMetrics {
+	m := &SmartctlMetrics{
+		executor: executor,
+		devices:  make(map[string]*SmartctlInfo),
+		pdisks:   make(map[string]string),
+		serials:  make(map[string]bool),
+		exporter: exporter.NewCollectorMetrics("smartctl"),
+		breaker:  exporter.NewBreaker("smartctl", exporter.DefaultBackoff),
+	}
+	registry.MustRegister(m)
+	return m
+}
+
+// Describe implements prometheus.Collector.
+func (m *SmartctlMetrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		smartctlInfoDesc,
+		smartctlPassedDesc,
+		smartctlTemperatureDesc,
+		smartctlPowerOnHoursDesc,
+		smartctlExitStatusDesc,
+		ataAttributeValueDesc,
+		ataAttributeWorstDesc,
+		ataAttributeThresholdDesc,
+		ataAttributeRawDesc,
+		scsiErrorsCorrectedDesc,
+		scsiErrorsUncorrectedDesc,
+		scsiProcessedBytesDesc,
+		scsiGrownDefectsDesc,
+	} {
+		ch <- desc
+	}
+	m.exporter.Describe(ch)
+	m.breaker.Describe(ch)
+}
+
+// Collect implements prometheus.Collector. It runs smartctl unless the previous
+// run is younger than MinInterval or the breaker is backing off after failures,
+// in which case the previous results are served.
+func (m *SmartctlMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if (m.lastUpdate.IsZero() || time.Since(m.lastUpdate) >= m.MinInterval) && m.breaker.Allow() {
+		err := m.update()
+		if err != nil {
+			log.Printf("Error collecting smartctl metrics: %v", err)
+		}
+		m.breaker.Record(err)
+	}
+	for device, info := range m.devices {
+		collectSmartctlInfo(ch, device, info)
+	}
+	m.exporter.Collect(ch)
+	m.breaker.Collect(ch)
+}
+
+// Update runs smartctl once. It returns an error when the drives cannot be
+// listed. A drive that cannot be read keeps its previous series and is only
+// counted in command_errors_total.
+func (m *SmartctlMetrics) Update() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.update()
+}
+
+func (m *SmartctlMetrics) update() error {
+	start := time.Now()
+	m.lastUpdate = start
+	devices, err := m.Scan()
+	if err != nil {
+		m.exporter.CommandError("Scan", err)
+		m.exporter.Observe(start, err)
+		return err
+	}
+
+	current := make(map[string]*SmartctlInfo)
+	for _, device := range devices {
+		name := smartctlDeviceLabel(device)
+		info, err := m.GetDeviceInfo(device)
+		if err != nil {
+			log.Printf("Error reading %s with smartctl: %v", device.Name, err)
+			m.exporter.CommandError("GetDeviceInfo", err)
+			if previous, ok := m.devices[name]; ok {
+				current[name] = previous
+			}
+			continue
+		}
+		current[name] = info
+	}
+	m.resolvePDisks(current)
+	m.devices = current
+	m.exporter.Observe(start, nil)
+	return nil
+}
+
+// resolvePDisks sets the pdisk of the drives found in the PDisks inventory,
+// matched by serial number. The inventory is read again when a serial number
+// was not seen at the previous read.
+func (m *SmartctlMetrics) resolvePDisks(devices map[string]*SmartctlInfo) {
+	if m.PDisks == nil {
+		return
+	}
+
+	serials := make(map[string]bool)
+	refresh := false
+	for _, info := range devices {
+		serial := strings.ToUpper(strings.TrimSpace(info.SerialNumber))
+		if serial == "" {
+			continue
+		}
+		serials[serial] = true
+		if !m.serials[serial] {
+			refresh = true
+		}
+	}
+	if refresh {
+		pdisks, err := m.PDisks()
+		if err != nil {
+			log.Printf("Error fetching physical disks: %v", err)
+			m.exporter.CommandError("PDisks", err)
+		} else {
+			m.pdisks = pdisks
+			m.serials = serials
+		}
+	}
+
+	for _, info := range devices {
+		info.pdisk = m.pdisks[strings.ToUpper(strings.TrimSpace(info.SerialNumber))]
+	}
+}
+
+// smartctlDeviceLabel returns the device label of a drive: the device name
+// without /dev/, followed by the device type for drives behind a controller,
+// which share the controller device, e.g. "bus/0:megaraid,3".
+func smartctlDeviceLabel(device SmartctlDevice) string {
+	name := strings.TrimPrefix(device.Name, "/dev/")
+	if strings.Contains(device.Type, "megaraid,") {
+		return name + ":" + device.Type
+	}
+	return name
+}
+
+// Scan returns the SATA and SAS drives found by smartctl --scan, or --scan-open
+// with MegaRAID. NVMe drives are skipped.
+func (m *SmartctlMetrics) Scan() ([]SmartctlDevice, error) {
+	scan := "--scan"
+	if m.MegaRAID {
+		scan = "--scan-open"
+	}
+	output, err := m.executor.ExecuteCommand("smartctl", scan, "--json")
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Devices []SmartctlDevice `json:"devices"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("error parsing smartctl %s output: %w", scan, err)
+	}
+
+	devices := make([]SmartctlDevice, 0, len(result.Devices))
+	for _, device := range result.Devices {
+		if strings.EqualFold(device.Protocol, "NVMe") || device.Type == "nvme" {
+			continue
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// GetDeviceInfo returns the smartctl --json -a output of device. smartctl sets
+// exit status bits for failing drives, so the output is used unless smartctl
+// could not read the drive at all.
+func (m *SmartctlMetrics) GetDeviceInfo(device SmartctlDevice) (*SmartctlInfo, error) {
+	args := []string{"--json", "-a"}
+	if device.Type != "" {
+		args = append(args, "-d", device.Type)
+	}
+	output, err := m.executor.ExecuteCommand("smartctl", append(args, device.Name)...)
+
+	var info SmartctlInfo
+	if jsonErr := json.Unmarshal(output, &info); jsonErr != nil {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error parsing smartctl output of %s: %w", device.Name, jsonErr)
+	}
+	if info.Smartctl.ExitStatus&smartctlFatalExitStatus != 0 {
+		for _, message := range info.Smartctl.Messages {
+			if message.Severity == "error" {
+				return nil, fmt.Errorf("smartctl %s: %s", device.Name, message.String)
+			}
+		}
+		return nil, fmt.Errorf("smartctl %s: exit status %d", device.Name, info.Smartctl.ExitStatus)
+	}
+	return &info, nil
+}
+
+// collectSmartctlInfo sends the metrics of the drive device.
+func collectSmartctlInfo(ch chan<- prometheus.Metric, device string, info *SmartctlInfo) {
+	ch <- prometheus.MustNewConstMetric(smartctlInfoDesc, prometheus.GaugeValue, 1,
+		device, info.Device.Type, info.Device.Protocol, info.ModelFamily, info.model(), info.SerialNumber, info.firmware(), info.pdisk)
+	ch <- prometheus.MustNewConstMetric(smartctlExitStatusDesc, prometheus.GaugeValue, float64(info.Smartctl.ExitStatus), device)
+	if info.SmartStatus != nil {
+		passed := 0.0
+		if info.SmartStatus.Passed {
+			passed = 1
+		}
+		ch <- prometheus.MustNewConstMetric(smartctlPassedDesc, prometheus.GaugeValue, passed, device)
+	}
+	if info.Temperature.Current != nil {
+		ch <- prometheus.MustNewConstMetric(smartctlTemperatureDesc, prometheus.GaugeValue, *info.Temperature.Current, device)
+	}
+	if info.PowerOnTime.Hours != nil {
+		ch <- prometheus.MustNewConstMetric(smartctlPowerOnHoursDesc, prometheus.CounterValue, *info.PowerOnTime.Hours, device)
+	}
+
+	for _, attribute := range info.ATASmartAttributes.Table {
+		labels := []string{device, strconv.Itoa(attribute.ID), attribute.Name}
+		ch <- prometheus.MustNewConstMetric(ataAttributeValueDesc, prometheus.GaugeValue, attribute.Value, labels...)
+		ch <- prometheus.MustNewConstMetric(ataAttributeWorstDesc, prometheus.GaugeValue, attribute.Worst, labels...)
+		ch <- prometheus.MustNewConstMetric(ataAttributeThresholdDesc, prometheus.GaugeValue, attribute.Thresh, labels...)
+		ch <- prometheus.MustNewConstMetric(ataAttributeRawDesc, prometheus.GaugeValue, attribute.Raw.Value, labels...)
+	}
+
+	for operation, counter := range info.ScsiErrorCounterLog {
+		ch <- prometheus.MustNewConstMetric(scsiErrorsCorrectedDesc, prometheus.CounterValue, counter.TotalErrorsCorrected, device, operation)
+		ch <- prometheus.MustNewConstMetric(scsiErrorsUncorrectedDesc, prometheus.CounterValue, counter.TotalUncorrectedErrors, device, operation)
+		if gigabytes, err := strconv.ParseFloat(counter.GigabytesProcessed, 64); err == nil {
+			ch <- prometheus.MustNewConstMetric(scsiProcessedBytesDesc, prometheus.CounterValue, gigabytes*1e9, device, operation)
+		}
+	}
+	if info.ScsiGrownDefectList != nil {
+		ch <- prometheus.MustNewConstMetric(scsiGrownDefectsDesc, prometheus.GaugeValue, *info.ScsiGrownDefectList, device)
+	}
+}
@@ -0,0 +1,222 @@
+package smart
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newSmartctlMockExecutor(t *testing.T) *CommandMockExecutor {
+	return &CommandMockExecutor{
+		Outputs: map[string]string{
+			"smartctl --scan --json":              readTestdata(t, "smartctl/scan.json"),
+			"smartctl --json -a -d sat /dev/sda":  readTestdata(t, "smartctl/sda.json"),
+			"smartctl --json -a -d scsi /dev/sdb": readTestdata(t, "smartctl/sdb.json"),
+		},
+	}
+}
+
+func TestSmartctlScan(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := NewSmartctlMetrics(newSmartctlMockExecutor(t), registry)
+
+	devices, err := metrics.Scan()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// The NVMe drive is left to the NVMe collector
+	if len(devices) != 2 || devices[0].Name != "/dev/sda" || devices[1].Type != "scsi" {
+		t.Fatalf("Unexpected devices %+v", devices)
+	}
+}
+
+func TestCollectSmartctl(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	NewSmartctlMetrics(newSmartctlMockExecutor(t), registry)
+
+	expected := `
+# HELP smartctl_ata_attribute_raw_value Raw value of the ATA SMART attribute, vendor specific
+# TYPE smartctl_ata_attribute_raw_value gauge
+smartctl_ata_attribute_raw_value{attribute_id="1",attribute_name="Raw_Read_Error_Rate",device="sda"} 0
+smartctl_ata_attribute_raw_value{attribute_id="202",attribute_name="Percent_Lifetime_Remain",device="sda"} 3
+smartctl_ata_attribute_raw_value{attribute_id="5",attribute_name="Reallocated_Sector_Ct",device="sda"} 2
+smartctl_ata_attribute_raw_value{attribute_id="9",attribute_name="Power_On_Hours",device="sda"} 31466
+# HELP smartctl_ata_attribute_threshold Failure threshold of the normalized value of the ATA SMART attribute
+# TYPE smartctl_ata_attribute_threshold gauge
+smartctl_ata_attribute_threshold{attribute_id="1",attribute_name="Raw_Read_Error_Rate",device="sda"} 50
+smartctl_ata_attribute_threshold{attribute_id="202",attribute_name="Percent_Lifetime_Remain",device="sda"} 1
+smartctl_ata_attribute_threshold{attribute_id="5",attribute_name="Reallocated_Sector_Ct",device="sda"} 1
+smartctl_ata_attribute_threshold{attribute_id="9",attribute_name="Power_On_Hours",device="sda"} 0
+# HELP smartctl_ata_attribute_value Normalized value of the ATA SMART attribute
+# TYPE smartctl_ata_attribute_value gauge
+smartctl_ata_attribute_value{attribute_id="1",attribute_name="Raw_Read_Error_Rate",device="sda"} 100
+smartctl_ata_attribute_value{attribute_id="202",attribute_name="Percent_Lifetime_Remain",device="sda"} 97
+smartctl_ata_attribute_value{attribute_id="5",attribute_name="Reallocated_Sector_Ct",device="sda"} 100
+smartctl_ata_attribute_value{attribute_id="9",attribute_name="Power_On_Hours",device="sda"} 100
+# HELP smartctl_device_info Information about the drive read with smartctl, always 1
+# TYPE smartctl_device_info gauge
+smartctl_device_info{device="sda",firmware="D1DF003",model="MTFDDAK480TDN",model_family="Micron 5100 Pro / 5200 SSDs",pdisk="",protocol="ATA",serial="18231C4A1B2D",type="sat"} 1
+smartctl_device_info{device="sdb",firmware="C003",model="SEAGATE ST2400MM0129",model_family="",pdisk="",protocol="SCSI",serial="WFN0A1B2",type="scsi"} 1
+# HELP smartctl_device_smart_passed Whether the drive passed its SMART overall-health self-assessment
+# TYPE smartctl_device_smart_passed gauge
+smartctl_device_smart_passed{device="sda"} 1
+smartctl_device_smart_passed{device="sdb"} 1
+# HELP smartctl_device_temperature_celsius Current temperature of the drive in degrees Celsius
+# TYPE smartctl_device_temperature_celsius gauge
+smartctl_device_temperature_celsius{device="sda"} 29
+smartctl_device_temperature_celsius{device="sdb"} 34
+# HELP smartctl_scsi_errors_uncorrected_total Number of uncorrected errors of the SAS drive, by operation (read, write, verify)
+# TYPE smartctl_scsi_errors_uncorrected_total counter
+smartctl_scsi_errors_uncorrected_total{device="sdb",operation="read"} 0
+smartctl_scsi_errors_uncorrected_total{device="sdb",operation="write"} 1
+# HELP smartctl_scsi_grown_defects Number of entries in the grown defect list of the SAS drive
+# TYPE smartctl_scsi_grown_defects gauge
+smartctl_scsi_grown_defects{device="sdb"} 8
+# HELP smartctl_scsi_processed_bytes_total Bytes processed by the SAS drive, by operation (read, write, verify)
+# TYPE smartctl_scsi_processed_bytes_total counter
+smartctl_scsi_processed_bytes_total{device="sdb",operation="read"} 4.98155361e+14
+smartctl_scsi_processed_bytes_total{device="sdb",operation="write"} 8.1265504e+13
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"smartctl_ata_attribute_raw_value", "smartctl_ata_attribute_threshold", "smartctl_ata_attribute_value",
+		"smartctl_device_info", "smartctl_device_smart_passed", "smartctl_device_temperature_celsius",
+		"smartctl_scsi_errors_uncorrected_total", "smartctl_scsi_grown_defects", "smartctl_scsi_processed_bytes_total"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+// exitStatusExecutor returns its output together with an exit error, like smartctl
+// reporting a failing drive.
+type exitStatusExecutor struct {
+	output string
+}
+
+func (e *exitStatusExecutor) ExecuteCommand(name string, args ...string) ([]byte, error) {
+	return []byte(e.output), errors.New("exit status 2")
+}
+
+func TestSmartctlGetDeviceInfoExitStatus(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := NewSmartctlMetrics(&exitStatusExecutor{output: `{
+  "smartctl": {
+    "exit_status": 2,
+    "messages": [{"string": "Smartctl open device: /dev/sdc failed: No such device", "severity": "error"}]
+  }
+}`}, registry)
+
+	_, err := metrics.GetDeviceInfo(SmartctlDevice{Name: "/dev/sdc", Type: "sat"})
+	if err == nil || !strings.Contains(err.Error(), "No such device") {
+		t.Fatalf("Expected the smartctl error message, got %v", err)
+	}
+
+	// Health bits come with a full output
+	metrics = NewSmartctlMetrics(&exitStatusExecutor{output: readTestdata(t, "smartctl/sdb.json")}, prometheus.NewRegistry())
+	info, err := metrics.GetDeviceInfo(SmartctlDevice{Name: "/dev/sdb", Type: "scsi"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if info.Smartctl.ExitStatus != 4 || info.SerialNumber != "WFN0A1B2" {
+		t.Fatalf("Unexpected info %+v", info)
+	}
+}
+
+func TestCollectSmartctlScanFailure(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	NewSmartctlMetrics(&MockCommandExecutor{MockError: errors.New("smartctl: executable file not found")}, registry)
+
+	expected := `
+# HELP dell_disk_exporter_collector_success Whether the last collection succeeded
+# TYPE dell_disk_exporter_collector_success gauge
+dell_disk_exporter_collector_success{collector="smartctl"} 0
+# HELP dell_disk_exporter_command_errors_total Number of failed commands by reason (timeout, not_found, exit_status, error)
+# TYPE dell_disk_exporter_command_errors_total counter
+dell_disk_exporter_command_errors_total{collector="smartctl",command="Scan",reason="error"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"dell_disk_exporter_collector_success", "dell_disk_exporter_command_errors_total"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestCollectSmartctlDeviceFailure(t *testing.T) {
+	mockExecutor := newSmartctlMockExecutor(t)
+	mockExecutor.Errors = map[string]error{"smartctl --json -a -d scsi /dev/sdb": errors.New("exit status 2")}
+
+	registry := prometheus.NewRegistry()
+	NewSmartctlMetrics(mockExecutor, registry)
+
+	// The drive that cannot be read does not back off the other one
+	sda := readTestdata(t, "smartctl/sda.json")
+	mockExecutor.Outputs["smartctl --json -a -d sat /dev/sda"] = strings.Replace(sda, `"current": 29`, `"current": 31`, 1)
+	if _, err := registry.Gather(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	mockExecutor.Outputs["smartctl --json -a -d sat /dev/sda"] = strings.Replace(sda, `"current": 29`, `"current": 33`, 1)
+
+	expected := `
+# HELP dell_disk_exporter_collector_breaker_state State of the collector circuit breaker (0=Closed, 1=Open, 2=HalfOpen)
+# TYPE dell_disk_exporter_collector_breaker_state gauge
+dell_disk_exporter_collector_breaker_state{collector="smartctl"} 0
+# HELP dell_disk_exporter_collector_success Whether the last collection succeeded
+# TYPE dell_disk_exporter_collector_success gauge
+dell_disk_exporter_collector_success{collector="smartctl"} 1
+# HELP dell_disk_exporter_command_errors_total Number of failed commands by reason (timeout, not_found, exit_status, error)
+# TYPE dell_disk_exporter_command_errors_total counter
+dell_disk_exporter_command_errors_total{collector="smartctl",command="GetDeviceInfo",reason="error"} 2
+# HELP smartctl_device_temperature_celsius Current temperature of the drive in degrees Celsius
+# TYPE smartctl_device_temperature_celsius gauge
+smartctl_device_temperature_celsius{device="sda"} 33
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"dell_disk_exporter_collector_breaker_state", "dell_disk_exporter_collector_success",
+		"dell_disk_exporter_command_errors_total", "smartctl_device_temperature_celsius"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestCollectSmartctlMegaRAID(t *testing.T) {
+	mockExecutor := &CommandMockExecutor{
+		Outputs: map[string]string{
+			"smartctl --scan-open --json":                     readTestdata(t, "smartctl/scan-open.json"),
+			"smartctl --json -a -d megaraid,0 /dev/bus/0":     readTestdata(t, "smartctl/megaraid-0.json"),
+			"smartctl --json -a -d sat+megaraid,1 /dev/bus/0": readTestdata(t, "smartctl/megaraid-1.json"),
+		},
+	}
+
+	registry := prometheus.NewRegistry()
+	metrics := NewSmartctlMetrics(mockExecutor, registry)
+	metrics.MegaRAID = true
+	calls := 0
+	metrics.PDisks = func() (map[string]string, error) {
+		calls++
+		return map[string]string{
+			"ZC200000":           "Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1",
+			"PHYF000000AA480BGN": "Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1",
+		}, nil
+	}
+
+	expected := `
+# HELP smartctl_device_info Information about the drive read with smartctl, always 1
+# TYPE smartctl_device_info gauge
+smartctl_device_info{device="bus/0:megaraid,0",firmware="DSF7",model="SEAGATE ST2000NM0135",model_family="",pdisk="Disk.Bay.1:Enclosure.Internal.0-1:RAID.Integrated.1-1",protocol="SCSI",serial="ZC200000",type="megaraid,0"} 1
+smartctl_device_info{device="bus/0:sat+megaraid,1",firmware="XCV1DL67",model="SSDSC2KB480G8R",model_family="Intel S4510/S4610/S4500/S4600 Series SSDs",pdisk="Disk.Bay.0:Enclosure.Internal.0-1:RAID.Integrated.1-1",protocol="ATA",serial="PHYF000000AA480BGN",type="sat+megaraid,1"} 1
+# HELP smartctl_scsi_grown_defects Number of entries in the grown defect list of the SAS drive
+# TYPE smartctl_scsi_grown_defects gauge
+smartctl_scsi_grown_defects{device="bus/0:megaraid,0"} 112
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"smartctl_device_info", "smartctl_scsi_grown_defects"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+
+	// The inventory is only read again when a new drive shows up
+	if _, err := registry.Gather(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("Expected the physical disks to be read once, got %d calls", calls)
+	}
+}
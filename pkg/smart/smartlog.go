@@ -0,0 +1,123 @@
+package smart
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dataUnitBytes is the size of the data units reported by the SMART log,
+// 1000 blocks of 512 bytes.
+const dataUnitBytes = 512000
+
+// smartLogField maps a field of the nvme smart-log JSON output to a typed metric.
+// The exported value is value*scale + offset.
+type smartLogField struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	scale     float64
+	offset    float64
+	// labels are appended to the device label, e.g. the thermal management level.
+	labels []string
+}
+
+func newSmartLogField(name, help string, valueType prometheus.ValueType, scale, offset float64) smartLogField {
+	return smartLogField{
+		desc:      prometheus.NewDesc(name, help, []string{"device"}, nil),
+		valueType: valueType,
+		scale:     scale,
+		offset:    offset,
+	}
+}
+
+// newThermalField maps the thermal management counter of level to desc.
+func newThermalField(desc *prometheus.Desc, level string) smartLogField {
+	return smartLogField{desc: desc, valueType: prometheus.CounterValue, scale: 1, labels: []string{level}}
+}
+
+var (
+	thermalTransitionsDesc = prometheus.NewDesc(
+		"nvme_thermal_management_transitions_total",
+		"Number of times the controller entered a thermal management temperature level",
+		[]string{"device", "level"}, nil,
+	)
+	thermalTimeDesc = prometheus.NewDesc(
+		"nvme_thermal_management_time_seconds_total",
+		"Time spent in a thermal management temperature level in seconds",
+		[]string{"device", "level"}, nil,
+	)
+
+	// smartLogFields are the typed metrics derived from the SMART log. Temperatures
+	// are exported by collectTemperatures, other fields missing from the table are
+	// only exported by the legacy nvme_smart_log gauge.
+	smartLogFields = map[string]smartLogField{
+		"critical_warning": newSmartLogField("nvme_critical_warning",
+			"Critical warning bit field of the SMART log, 0 when no warning is raised", prometheus.GaugeValue, 1, 0),
+		"avail_spare": newSmartLogField("nvme_available_spare_ratio",
+			"Remaining spare capacity, from 0 to 1", prometheus.GaugeValue, 0.01, 0),
+		"spare_thresh": newSmartLogField("nvme_available_spare_threshold_ratio",
+			"Spare capacity below which a critical warning is raised, from 0 to 1", prometheus.GaugeValue, 0.01, 0),
+		"percent_used": newSmartLogField("nvme_percentage_used_ratio",
+			"Vendor estimate of the used endurance, may exceed 1", prometheus.GaugeValue, 0.01, 0),
+		"endurance_grp_critical_warning_summary": newSmartLogField("nvme_endurance_group_critical_warning",
+			"Critical warning bit field summarizing the endurance groups", prometheus.GaugeValue, 1, 0),
+		"data_units_read": newSmartLogField("nvme_data_units_read_bytes_total",
+			"Bytes read by the host", prometheus.CounterValue, dataUnitBytes, 0),
+		"data_units_written": newSmartLogField("nvme_data_units_written_bytes_total",
+			"Bytes written by the host", prometheus.CounterValue, dataUnitBytes, 0),
+		"host_read_commands": newSmartLogField("nvme_host_read_commands_total",
+			"Number of read commands completed by the controller", prometheus.CounterValue, 1, 0),
+		"host_write_commands": newSmartLogField("nvme_host_write_commands_total",
+			"Number of write commands completed by the controller", prometheus.CounterValue, 1, 0),
+		"controller_busy_time": newSmartLogField("nvme_controller_busy_time_seconds_total",
+			"Time the controller was busy with I/O commands in seconds", prometheus.CounterValue, 60, 0),
+		"power_cycles": newSmartLogField("nvme_power_cycles_total",
+			"Number of power cycles", prometheus.CounterValue, 1, 0),
+		"power_on_hours": newSmartLogField("nvme_power_on_hours_total",
+			"Number of power-on hours", prometheus.CounterValue, 1, 0),
+		"unsafe_shutdowns": newSmartLogField("nvme_unsafe_shutdowns_total",
+			"Number of unsafe shutdowns", prometheus.CounterValue, 1, 0),
+		"media_errors": newSmartLogField("nvme_media_errors_total",
+			"Number of unrecovered data integrity errors", prometheus.CounterValue, 1, 0),
+		"num_err_log_entries": newSmartLogField("nvme_num_err_log_entries_total",
+			"Number of error information log entries over the life of the controller", prometheus.CounterValue, 1, 0),
+		"warning_temp_time": newSmartLogField("nvme_warning_temperature_time_seconds_total",
+			"Time spent above the warning composite temperature threshold in seconds", prometheus.CounterValue, 60, 0),
+		"critical_comp_time": newSmartLogField("nvme_critical_temperature_time_seconds_total",
+			"Time spent above the critical composite temperature threshold in seconds", prometheus.CounterValue, 60, 0),
+		"thm_temp1_trans_count": newThermalField(thermalTransitionsDesc, "1"),
+		"thm_temp2_trans_count": newThermalField(thermalTransitionsDesc, "2"),
+		"thm_temp1_total_time":  newThermalField(thermalTimeDesc, "1"),
+		"thm_temp2_total_time":  newThermalField(thermalTimeDesc, "2"),
+	}
+
+	smartLogDesc = prometheus.NewDesc(
+		"nvme_smart_log",
+		"SMART log metrics for NVMe devices",
+		[]string{"device", "metric"}, nil,
+	)
+)
+
+// describeSmartLog sends the descriptors of the typed metrics and of the legacy gauge.
+func describeSmartLog(ch chan<- *prometheus.Desc) {
+	described := make(map[*prometheus.Desc]bool)
+	for _, field := range smartLogFields {
+		if !described[field.desc] {
+			described[field.desc] = true
+			ch <- field.desc
+		}
+	}
+	ch <- smartLogDesc
+}
+
+// collectSmartLog sends the SMART log values of drive as typed metrics, and as
+// the legacy nvme_smart_log gauge if legacy is set.
+func collectSmartLog(ch chan<- prometheus.Metric, drive string, values map[string]float64, legacy bool) {
+	for key, value := range values {
+		if field, ok := smartLogFields[key]; ok {
+			labels := append([]string{drive}, field.labels...)
+			ch <- prometheus.MustNewConstMetric(field.desc, field.valueType, value*field.scale+field.offset, labels...)
+		}
+		if legacy {
+			ch <- prometheus.MustNewConstMetric(smartLogDesc, prometheus.GaugeValue, value, drive, key)
+		}
+	}
+}
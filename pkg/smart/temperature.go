@@ -0,0 +1,152 @@
+package smart
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CompositeSensor is the sensor label of the composite controller temperature.
+const CompositeSensor = "composite"
+
+var (
+	// temperatureSensorKey matches the JSON (temperature_sensor_1) and text
+	// (Temperature Sensor 1) names of the temperature sensors.
+	temperatureSensorKey = regexp.MustCompile(`(?i)^temperature[ _]sensor[ _](\d+)$`)
+	// temperatureValue matches "301", "28 C", "28 °C (301 K)" or "301 Kelvin".
+	temperatureValue = regexp.MustCompile(`^(-?\d+(?:\.\d+)?)\s*(°?C|K|Kelvin)?\b`)
+
+	temperatureDesc = prometheus.NewDesc(
+		"nvme_temperature_celsius",
+		"Temperature of the controller in degrees Celsius, by sensor (composite or the sensor number)",
+		[]string{"device", "sensor"}, nil,
+	)
+	warningThresholdDesc = prometheus.NewDesc(
+		"nvme_temperature_warning_threshold_celsius",
+		"Warning composite temperature threshold (WCTEMP) of the controller in degrees Celsius",
+		[]string{"device"}, nil,
+	)
+	criticalThresholdDesc = prometheus.NewDesc(
+		"nvme_temperature_critical_threshold_celsius",
+		"Critical composite temperature threshold (CCTEMP) of the controller in degrees Celsius",
+		[]string{"device"}, nil,
+	)
+)
+
+// temperatureThresholds are the composite temperature thresholds reported by
+// nvme id-ctrl, in degrees Celsius. Zero values are not reported by the controller.
+type temperatureThresholds struct {
+	warning  float64
+	critical float64
+}
+
+// temperatureSensor returns the sensor label of a SMART log field holding a
+// temperature, or false for other fields.
+func temperatureSensor(key string) (string, bool) {
+	if strings.EqualFold(key, "temperature") {
+		return CompositeSensor, true
+	}
+	if match := temperatureSensorKey.FindStringSubmatch(key); match != nil {
+		return match[1], true
+	}
+	return "", false
+}
+
+// kelvinToCelsius converts the integer Kelvin reported by NVMe controllers the
+// way nvme-cli displays them.
+func kelvinToCelsius(kelvin float64) float64 {
+	return kelvin - 273
+}
+
+// parseTemperature converts a SMART log temperature to degrees Celsius. JSON
+// values are in Kelvin, text values carry their unit.
+func parseTemperature(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return kelvinToCelsius(v), nil
+	case string:
+		match := temperatureValue.FindStringSubmatch(strings.TrimSpace(v))
+		if match == nil {
+			return 0, fmt.Errorf("invalid temperature %q", v)
+		}
+		parsed, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid temperature %q: %w", v, err)
+		}
+		if strings.HasSuffix(match[2], "C") {
+			return parsed, nil
+		}
+		return kelvinToCelsius(parsed), nil
+	default:
+		return 0, fmt.Errorf("invalid temperature %v", value)
+	}
+}
+
+// parseTemperatures returns the temperatures found in a SMART log keyed by
+// sensor, without the sensors the controller does not implement.
+func parseTemperatures(logData map[string]interface{}) map[string]float64 {
+	temperatures := make(map[string]float64)
+	for key, value := range logData {
+		sensor, ok := temperatureSensor(key)
+		if !ok {
+			continue
+		}
+		celsius, err := parseTemperature(value)
+		// Sensors reading 0 Kelvin are not implemented by the controller.
+		if err != nil || celsius == kelvinToCelsius(0) {
+			continue
+		}
+		temperatures[sensor] = celsius
+	}
+	return temperatures
+}
+
+// parseThresholds reads wctemp and cctemp, in Kelvin, from the nvme id-ctrl output.
+func parseThresholds(idCtrl map[string]interface{}) temperatureThresholds {
+	var thresholds temperatureThresholds
+	if kelvin, ok := numericValue(idCtrl["wctemp"]); ok && kelvin > 0 {
+		thresholds.warning = kelvinToCelsius(kelvin)
+	}
+	if kelvin, ok := numericValue(idCtrl["cctemp"]); ok && kelvin > 0 {
+		thresholds.critical = kelvinToCelsius(kelvin)
+	}
+	return thresholds
+}
+
+// numericValue returns value as a float64 if it is a JSON number or a decimal string.
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return parsed, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func describeTemperatures(ch chan<- *prometheus.Desc) {
+	ch <- temperatureDesc
+	ch <- warningThresholdDesc
+	ch <- criticalThresholdDesc
+}
+
+// collectTemperatures sends the temperatures and thresholds of drive.
+func collectTemperatures(ch chan<- prometheus.Metric, drive string, temperatures map[string]float64, thresholds *temperatureThresholds) {
+	for sensor, celsius := range temperatures {
+		ch <- prometheus.MustNewConstMetric(temperatureDesc, prometheus.GaugeValue, celsius, drive, sensor)
+	}
+	if thresholds == nil {
+		return
+	}
+	if thresholds.warning != 0 {
+		ch <- prometheus.MustNewConstMetric(warningThresholdDesc, prometheus.GaugeValue, thresholds.warning, drive)
+	}
+	if thresholds.critical != 0 {
+		ch <- prometheus.MustNewConstMetric(criticalThresholdDesc, prometheus.GaugeValue, thresholds.critical, drive)
+	}
+}
@@ -0,0 +1,93 @@
+package smart
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseTemperature(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected float64
+	}{
+		{float64(301), 28},
+		{"301", 28},
+		{"28 C", 28},
+		{"28°C", 28},
+		{"28 °C (301 K)", 28},
+		{"33 C (306 Kelvin)", 33},
+		{"306 Kelvin", 33},
+		{"-5 C", -5},
+	}
+	for _, test := range tests {
+		celsius, err := parseTemperature(test.value)
+		if err != nil {
+			t.Errorf("parseTemperature(%q): unexpected error %v", test.value, err)
+			continue
+		}
+		if celsius != test.expected {
+			t.Errorf("parseTemperature(%q) = %v, expected %v", test.value, celsius, test.expected)
+		}
+	}
+
+	for _, value := range []interface{}{"", "N/A", true} {
+		if _, err := parseTemperature(value); err == nil {
+			t.Errorf("parseTemperature(%v): expected an error", value)
+		}
+	}
+}
+
+func TestParseTemperaturesUnimplementedSensor(t *testing.T) {
+	temperatures := parseTemperatures(map[string]interface{}{
+		"temperature":          float64(301),
+		"temperature_sensor_1": float64(306),
+		"temperature_sensor_2": float64(0),
+		"Temperature Sensor 3": "-273 C (0 Kelvin)",
+	})
+	expected := map[string]float64{CompositeSensor: 28, "1": 33}
+	if !reflect.DeepEqual(temperatures, expected) {
+		t.Fatalf("Expected %v, got %v", expected, temperatures)
+	}
+}
+
+func TestCollectTemperatures(t *testing.T) {
+	mockExecutor := newNVMeMockExecutor()
+	// Text output of nvme-cli versions without JSON support
+	mockExecutor.Outputs[smartLogCommand] = `Smart Log for NVME device:nvme0n1 namespace-id:ffffffff
+critical_warning                    : 0
+temperature                         : 28 C (301 Kelvin)
+Temperature Sensor 1                : 33 C (306 Kelvin)
+Temperature Sensor 2                : 28 C (301 Kelvin)
+`
+	mockExecutor.Outputs[idCtrlCommand] = `{"vid" : 5197, "wctemp" : 343, "cctemp" : 358}`
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	NewMetrics(mockExecutor, registry, 5*time.Minute)
+
+	expected := `
+# HELP nvme_temperature_celsius Temperature of the controller in degrees Celsius, by sensor (composite or the sensor number)
+# TYPE nvme_temperature_celsius gauge
+nvme_temperature_celsius{device="nvme0n1",sensor="1"} 33
+nvme_temperature_celsius{device="nvme0n1",sensor="2"} 28
+nvme_temperature_celsius{device="nvme0n1",sensor="composite"} 28
+# HELP nvme_temperature_critical_threshold_celsius Critical composite temperature threshold (CCTEMP) of the controller in degrees Celsius
+# TYPE nvme_temperature_critical_threshold_celsius gauge
+nvme_temperature_critical_threshold_celsius{device="nvme0n1"} 85
+# HELP nvme_temperature_warning_threshold_celsius Warning composite temperature threshold (WCTEMP) of the controller in degrees Celsius
+# TYPE nvme_temperature_warning_threshold_celsius gauge
+nvme_temperature_warning_threshold_celsius{device="nvme0n1"} 70
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nvme_temperature_celsius",
+		"nvme_temperature_critical_threshold_celsius", "nvme_temperature_warning_threshold_celsius"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
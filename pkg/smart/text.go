@@ -0,0 +1,90 @@
+package smart
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// textKeyAliases maps the normalized names printed by the nvme-cli text
+	// output to the field names of its JSON output.
+	textKeyAliases = map[string]string{
+		"available_spare":                          "avail_spare",
+		"available_spare_threshold":                "spare_thresh",
+		"percentage_used":                          "percent_used",
+		"endurance_group_critical_warning_summary": "endurance_grp_critical_warning_summary",
+		"warning_temperature_time":                 "warning_temp_time",
+		"critical_composite_temperature_time":      "critical_comp_time",
+		"thermal_management_t1_trans_count":        "thm_temp1_trans_count",
+		"thermal_management_t2_trans_count":        "thm_temp2_trans_count",
+		"thermal_management_t1_total_time":         "thm_temp1_total_time",
+		"thermal_management_t2_total_time":         "thm_temp2_total_time",
+	}
+
+	textKeySeparators = regexp.MustCompile(`[^a-z0-9]+`)
+	// textNumber matches the leading number of a value such as "100%",
+	// "1,474,968,593 [755 TB]", "1474968593 (755.18 TB)" or "0x0".
+	textNumber = regexp.MustCompile(`^(0x[0-9a-fA-F]+|-?[0-9][0-9,]*(?:\.[0-9]+)?)`)
+)
+
+// parseNvmeText splits the "key : value" lines printed by nvme-cli. Keys are
+// lowercased with words joined by underscores, e.g. "Data Units Read" becomes
+// "data_units_read", and values are trimmed.
+func parseNvmeText(output string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.Trim(textKeySeparators.ReplaceAllString(strings.ToLower(key), "_"), "_")
+		if key == "" {
+			continue
+		}
+		fields[key] = strings.TrimSpace(value)
+	}
+	return fields
+}
+
+// parseNvmeSmartLogText parses the text output of nvme smart-log, printed by
+// nvme-cli versions without JSON support, into the fields and units of the JSON
+// output: keys are renamed, thousand separators, percent signs and annotations
+// such as "[755 TB]" are dropped and temperatures are converted to Kelvin.
+// Fields without a numeric value are skipped.
+func parseNvmeSmartLogText(output string) (map[string]interface{}, error) {
+	smartLog := make(map[string]interface{})
+	for key, value := range parseNvmeText(output) {
+		if alias, ok := textKeyAliases[key]; ok {
+			key = alias
+		}
+
+		if _, ok := temperatureSensor(key); ok {
+			celsius, err := parseTemperature(value)
+			if err != nil {
+				continue
+			}
+			smartLog[key] = celsius + 273
+			continue
+		}
+
+		if number, ok := parseTextNumber(value); ok {
+			smartLog[key] = number
+		}
+	}
+	return smartLog, nil
+}
+
+// parseTextNumber returns the leading decimal or hexadecimal number of value.
+func parseTextNumber(value string) (float64, bool) {
+	match := textNumber.FindString(value)
+	if match == "" {
+		return 0, false
+	}
+	if strings.HasPrefix(match, "0x") {
+		parsed, err := strconv.ParseUint(match[2:], 16, 64)
+		return float64(parsed), err == nil
+	}
+	parsed, err := strconv.ParseFloat(strings.ReplaceAll(match, ",", ""), 64)
+	return parsed, err == nil
+}
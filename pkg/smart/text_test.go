@@ -0,0 +1,106 @@
+package smart
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// TestParseNvmeSmartLogTextGolden parses the smart-log text output of several
+// nvme-cli versions for the same drive and compares it to testdata/smart-log/*.golden.json.
+func TestParseNvmeSmartLogTextGolden(t *testing.T) {
+	fixtures, err := filepath.Glob("testdata/smart-log/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("Expected smart-log fixtures")
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			output, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			smartLog, err := parseNvmeSmartLogText(string(output))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			got, err := json.MarshalIndent(smartLog, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := strings.TrimSuffix(fixture, ".txt") + ".golden.json"
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Error reading golden file, run go test -update: %v", err)
+			}
+			if string(got) != string(expected) {
+				t.Errorf("Parsed %s differs from %s:\n%s", fixture, golden, got)
+			}
+		})
+	}
+}
+
+func TestParseNvmeSmartLogTextMatchesJSON(t *testing.T) {
+	smartLog, err := parseNvmeSmartLogText(readTestdata(t, "smart-log/nvme-cli-2.8.txt"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Values of the JSON output of the same drive, see TestUpdateMetrics
+	expected := map[string]float64{
+		"temperature":          301,
+		"avail_spare":          100,
+		"spare_thresh":         5,
+		"percent_used":         15,
+		"data_units_written":   1474968593,
+		"host_write_commands":  51493840602,
+		"power_on_hours":       38313,
+		"num_err_log_entries":  17,
+		"critical_comp_time":   0,
+		"temperature_sensor_1": 306,
+		"thm_temp2_total_time": 0,
+	}
+	for key, value := range expected {
+		if smartLog[key] != value {
+			t.Errorf("Expected %s to be %v, got %v", key, value, smartLog[key])
+		}
+	}
+}
+
+func TestParseTextNumber(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected float64
+		ok       bool
+	}{
+		{"0", 0, true},
+		{"100%", 100, true},
+		{"1,474,968,593 [755 TB]", 1474968593, true},
+		{"1474968593 (755.18 TB)", 1474968593, true},
+		{"0x4", 4, true},
+		{"-1", -1, true},
+		{"nvme0n1 namespace-id:ffffffff", 0, false},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		value, ok := parseTextNumber(test.value)
+		if ok != test.ok || value != test.expected {
+			t.Errorf("parseTextNumber(%q) = %v, %v, expected %v, %v", test.value, value, ok, test.expected, test.ok)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package smart
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PCI vendor IDs of the drives with vendor logs registered by this package.
+const (
+	VendorIntel    uint16 = 0x8086
+	VendorSolidigm uint16 = 0x025e
+	VendorSamsung  uint16 = 0x144d
+	VendorMicron   uint16 = 0x1344
+)
+
+// ErrUnsupportedLog is returned by VendorLog.Decode when the data is not the
+// expected log page, e.g. a drive implementing another log at the same ID.
+var ErrUnsupportedLog = errors.New("unsupported log page")
+
+// VendorLog decodes a vendor specific log page of the NVMe drives, such as the
+// OCP SMART log, into metrics labelled with the device first.
+type VendorLog interface {
+	// Name identifies the log in logs and command_errors_total, e.g. "OCPSmartLog".
+	Name() string
+	// Args returns the nvme arguments printing the raw log page of drive.
+	Args(drive string) []string
+	// Describe sends the descriptors of every metric Decode may return.
+	Describe(ch chan<- *prometheus.Desc)
+	// Decode returns the metrics of the raw log page.
+	Decode(data []byte) ([]VendorMetric, error)
+}
+
+// VendorMetric is a value decoded from a vendor log page.
+type VendorMetric struct {
+	Desc      *prometheus.Desc
+	ValueType prometheus.ValueType
+	Value     float64
+	// LabelValues are the values of the labels of Desc after the device.
+	LabelValues []string
+}
+
+var (
+	vendorLogsMu sync.RWMutex
+	vendorLogs   = make(map[uint16][]VendorLog)
+)
+
+// RegisterVendorLog reads vendorLog from the drives of the PCI vendor
+// vendorID. Logs must be registered before NewMetrics, which describes them.
+// A log may be registered for several vendors.
+func RegisterVendorLog(vendorID uint16, vendorLog VendorLog) {
+	vendorLogsMu.Lock()
+	defer vendorLogsMu.Unlock()
+	vendorLogs[vendorID] = append(vendorLogs[vendorID], vendorLog)
+}
+
+func init() {
+	// Datacenter drives of these vendors implement the OCP SMART log; others
+	// are detected by its GUID and skipped.
+	for _, vendorID := range []uint16{VendorIntel, VendorSolidigm, VendorSamsung, VendorMicron} {
+		RegisterVendorLog(vendorID, OCPSmartLog{})
+	}
+	RegisterVendorLog(VendorIntel, IntelSmartLog{})
+	RegisterVendorLog(VendorSolidigm, IntelSmartLog{})
+}
+
+// vendorLogsFor returns the logs registered for vendorID.
+func vendorLogsFor(vendorID uint16) []VendorLog {
+	vendorLogsMu.RLock()
+	defer vendorLogsMu.RUnlock()
+	return vendorLogs[vendorID]
+}
+
+// describeVendorLogs sends the descriptors of every registered log once.
+func describeVendorLogs(ch chan<- *prometheus.Desc) {
+	vendorLogsMu.RLock()
+	defer vendorLogsMu.RUnlock()
+
+	described := make(map[string]bool)
+	for _, logs := range vendorLogs {
+		for _, vendorLog := range logs {
+			if !described[vendorLog.Name()] {
+				described[vendorLog.Name()] = true
+				vendorLog.Describe(ch)
+			}
+		}
+	}
+}
+
+// rawLogArgs returns the nvme arguments printing length bytes of the log page
+// logID of drive.
+func rawLogArgs(drive string, logID, length int) []string {
+	return []string{"get-log", "/dev/" + drive, fmt.Sprintf("--log-id=0x%02x", logID), "--log-len=" + strconv.Itoa(length), "--raw-binary"}
+}
+
+// GetVendorLog reads and decodes vendorLog from drive. The raw page is read
+// from the standard output of nvme alone when the executor implements
+// OutputExecutor. A drive rejecting the log page ID returns ErrUnsupportedLog.
+func (m *Metrics) GetVendorLog(drive string, vendorLog VendorLog) ([]VendorMetric, error) {
+	var output []byte
+	var err error
+	if executor, ok := m.executor.(OutputExecutor); ok {
+		output, err = executor.ExecuteOutput("nvme", vendorLog.Args(drive)...)
+	} else {
+		output, err = m.executor.ExecuteCommand("nvme", vendorLog.Args(drive)...)
+	}
+	if err != nil {
+		if invalidLogPage(output, err) {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedLog, err)
+		}
+		return nil, err
+	}
+	return vendorLog.Decode(output)
+}
+
+// invalidLogPage reports whether nvme failed because the controller returned
+// the Invalid Log Page status (0x109), printed on standard error.
+func invalidLogPage(output []byte, err error) bool {
+	message := string(output)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		message += string(exitErr.Stderr)
+	}
+	message = strings.ToLower(message)
+	return strings.Contains(message, "invalid log page") || strings.Contains(message, "invalid_log_page")
+}
+
+// readVendorLogs reads the vendor logs of controller through its namespace
+// drive, according to its vendor ID. Logs the controller does not implement,
+// failing with ErrUnsupportedLog, are not read again from this controller.
+// Other failures are retried on the next update.
+func (m *Metrics) readVendorLogs(controller, drive string, vendorID uint16) map[string][]VendorMetric {
+	values := make(map[string][]VendorMetric)
+	for _, vendorLog := range vendorLogsFor(vendorID) {
+		if m.unsupportedLogs[controller][vendorLog.Name()] {
+			continue
+		}
+		metrics, err := m.GetVendorLog(drive, vendorLog)
+		if err != nil {
+			if errors.Is(err, ErrUnsupportedLog) {
+				log.Printf("%s not supported by %s, skipping it: %v", vendorLog.Name(), controller, err)
+				if m.unsupportedLogs[controller] == nil {
+					m.unsupportedLogs[controller] = make(map[string]bool)
+				}
+				m.unsupportedLogs[controller][vendorLog.Name()] = true
+				continue
+			}
+			log.Printf("Error getting %s for %s: %v", vendorLog.Name(), drive, err)
+			m.exporter.CommandError(vendorLog.Name(), err)
+			continue
+		}
+		values[vendorLog.Name()] = metrics
+	}
+	return values
+}
+
+// collectVendorLogs sends the vendor log metrics of drive.
+func collectVendorLogs(ch chan<- prometheus.Metric, drive string, values map[string][]VendorMetric) {
+	for _, metrics := range values {
+		for _, metric := range metrics {
+			ch <- prometheus.MustNewConstMetric(metric.Desc, metric.ValueType, metric.Value,
+				append([]string{drive}, metric.LabelValues...)...)
+		}
+	}
+}
+
+// leUint returns the little-endian unsigned integer of up to 8 bytes in data.
+func leUint(data []byte) float64 {
+	buf := make([]byte, 8)
+	copy(buf, data)
+	return float64(binary.LittleEndian.Uint64(buf))
+}
+
+// leUint128 returns the little-endian 128-bit unsigned integer in data[:16].
+func leUint128(data []byte) float64 {
+	return leUint(data[:8]) + leUint(data[8:16])*math.Exp2(64)
+}
@@ -0,0 +1,231 @@
+package smart
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const (
+	ocpSmartLogCommand   = "nvme get-log /dev/nvme0n1 --log-id=0xc0 --log-len=512 --raw-binary"
+	intelSmartLogCommand = "nvme get-log /dev/nvme0n1 --log-id=0xca --log-len=512 --raw-binary"
+)
+
+// newVendorLogMockExecutor returns the executor of a drive of the PCI vendor
+// vendorID implementing the OCP and Intel SMART logs.
+func newVendorLogMockExecutor(t *testing.T, vendorID string) *CommandMockExecutor {
+	mockExecutor := newNVMeMockExecutor()
+	mockExecutor.Outputs[idCtrlCommand] = `{"vid" : ` + vendorID + `}`
+	mockExecutor.Outputs[ocpSmartLogCommand] = readTestdata(t, "vendor-log/ocp-c0.bin")
+	mockExecutor.Outputs[intelSmartLogCommand] = readTestdata(t, "vendor-log/intel-ca.bin")
+	return mockExecutor
+}
+
+func TestCollectVendorLogs(t *testing.T) {
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	// Solidigm (0x025e) drives implement both the OCP and the Intel logs
+	registry := prometheus.NewRegistry()
+	NewMetrics(newVendorLogMockExecutor(t, "606"), registry, 5*time.Minute)
+
+	expected := `
+# HELP nvme_intel_crc_errors_total Number of PCIe CRC errors
+# TYPE nvme_intel_crc_errors_total counter
+nvme_intel_crc_errors_total{device="nvme0n1"} 7
+# HELP nvme_intel_wear_leveling_erase_count Erase count of the NAND blocks by statistic: min, max or avg
+# TYPE nvme_intel_wear_leveling_erase_count gauge
+nvme_intel_wear_leveling_erase_count{device="nvme0n1",stat="avg"} 1411
+nvme_intel_wear_leveling_erase_count{device="nvme0n1",stat="max"} 1520
+nvme_intel_wear_leveling_erase_count{device="nvme0n1",stat="min"} 1302
+# HELP nvme_ocp_bad_user_nand_blocks Number of user NAND blocks retired
+# TYPE nvme_ocp_bad_user_nand_blocks gauge
+nvme_ocp_bad_user_nand_blocks{device="nvme0n1"} 12
+# HELP nvme_ocp_thermal_throttling_status Current thermal throttling status: 0 unthrottled, 1 first level, 2 second level, 3 third level
+# TYPE nvme_ocp_thermal_throttling_status gauge
+nvme_ocp_thermal_throttling_status{device="nvme0n1"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nvme_intel_crc_errors_total",
+		"nvme_intel_wear_leveling_erase_count", "nvme_ocp_bad_user_nand_blocks", "nvme_ocp_thermal_throttling_status"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestCollectVendorLogsOfOtherVendors(t *testing.T) {
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	// Samsung (0x144d) drives implement the OCP log only
+	registry := prometheus.NewRegistry()
+	NewMetrics(newVendorLogMockExecutor(t, "5197"), registry, 5*time.Minute)
+
+	if count := testutil.CollectAndCount(registry, "nvme_intel_crc_errors_total"); count != 0 {
+		t.Fatalf("Expected no nvme_intel_crc_errors_total series, got %d", count)
+	}
+	if count := testutil.CollectAndCount(registry, "nvme_ocp_bad_user_nand_blocks"); count != 1 {
+		t.Fatalf("Expected 1 nvme_ocp_bad_user_nand_blocks series, got %d", count)
+	}
+}
+
+func TestCollectVendorLogsOncePerController(t *testing.T) {
+	// The OCP log is only mocked for nvme0n1, reading it for nvme0n2 would fail
+	mockExecutor := newVendorLogMockExecutor(t, "5197")
+	for _, command := range []string{"smart-log", "id-ctrl", "id-ns", "self-test-log"} {
+		mockExecutor.Outputs["nvme "+command+" /dev/nvme0n2 --output-format json"] = mockExecutor.Outputs["nvme "+command+" /dev/nvme0n1 --output-format json"]
+	}
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = func(string) ([]string, error) { return []string{"nvme0n1", "nvme0n2"}, nil }
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	NewMetrics(mockExecutor, registry, 5*time.Minute)
+
+	expected := `
+# HELP nvme_ocp_bad_user_nand_blocks Number of user NAND blocks retired
+# TYPE nvme_ocp_bad_user_nand_blocks gauge
+nvme_ocp_bad_user_nand_blocks{device="nvme0n1"} 12
+nvme_ocp_bad_user_nand_blocks{device="nvme0n2"} 12
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nvme_ocp_bad_user_nand_blocks"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+	if count := testutil.CollectAndCount(registry, "dell_disk_exporter_command_errors_total"); count != 0 {
+		t.Fatalf("Expected no command error, got %d series", count)
+	}
+}
+
+func TestCollectUnsupportedVendorLog(t *testing.T) {
+	mockExecutor := newVendorLogMockExecutor(t, "4932")
+	mockExecutor.Outputs[ocpSmartLogCommand] = readTestdata(t, "vendor-log/ocp-c0-no-guid.bin")
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	NewMetrics(mockExecutor, registry, 5*time.Minute)
+
+	if count := testutil.CollectAndCount(registry, "nvme_ocp_bad_user_nand_blocks"); count != 0 {
+		t.Fatalf("Expected no nvme_ocp_bad_user_nand_blocks series, got %d", count)
+	}
+
+	// The log is not read again from the drive, which would fail here
+	delete(mockExecutor.Outputs, ocpSmartLogCommand)
+	if count := testutil.CollectAndCount(registry, "dell_disk_exporter_command_errors_total"); count != 0 {
+		t.Fatalf("Expected no command error, got %d series", count)
+	}
+}
+
+// nvmeExitError returns the error of a command exiting with status 1 after
+// printing stderr, as nvme does when the controller fails a command.
+func nvmeExitError(t *testing.T, stderr string) error {
+	t.Helper()
+	_, err := exec.Command("sh", "-c", "echo '"+stderr+"' >&2; exit 1").Output()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("Expected an exit error, got %v", err)
+	}
+	return err
+}
+
+func TestCollectVendorLogRejected(t *testing.T) {
+	mockExecutor := newVendorLogMockExecutor(t, "5197")
+	mockExecutor.Errors[ocpSmartLogCommand] = nvmeExitError(t, "NVMe status: Invalid Log Page: The log page indicated is invalid(0x109)")
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(mockExecutor, registry, 5*time.Minute)
+
+	if _, err := metrics.GetVendorLog("nvme0n1", OCPSmartLog{}); !errors.Is(err, ErrUnsupportedLog) {
+		t.Fatalf("Expected ErrUnsupportedLog, got %v", err)
+	}
+	// The log page is rejected by the drive, which is not an exporter error
+	if count := testutil.CollectAndCount(registry, "dell_disk_exporter_command_errors_total"); count != 0 {
+		t.Fatalf("Expected no command error, got %d series", count)
+	}
+	delete(mockExecutor.Errors, ocpSmartLogCommand)
+	if count := testutil.CollectAndCount(registry, "nvme_ocp_bad_user_nand_blocks"); count != 0 {
+		t.Fatalf("Expected the rejected log not to be read again, got %d series", count)
+	}
+}
+
+func TestCollectVendorLogRetriedAfterFailure(t *testing.T) {
+	mockExecutor := newVendorLogMockExecutor(t, "5197")
+	mockExecutor.Errors[ocpSmartLogCommand] = nvmeExitError(t, "get-log: Resource temporarily unavailable")
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	NewMetrics(mockExecutor, registry, 5*time.Minute)
+
+	expected := `
+# HELP dell_disk_exporter_command_errors_total Number of failed commands by reason (timeout, not_found, exit_status, error)
+# TYPE dell_disk_exporter_command_errors_total counter
+dell_disk_exporter_command_errors_total{collector="smart",command="OCPSmartLog",reason="exit_status"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "dell_disk_exporter_command_errors_total"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+
+	// Other failures are transient, the log is read again on the next update
+	delete(mockExecutor.Errors, ocpSmartLogCommand)
+	if count := testutil.CollectAndCount(registry, "nvme_ocp_bad_user_nand_blocks"); count != 1 {
+		t.Fatalf("Expected 1 nvme_ocp_bad_user_nand_blocks series, got %d", count)
+	}
+}
+
+type testVendorLog struct{}
+
+var testVendorLogDesc = prometheus.NewDesc("nvme_test_log_value", "Test log value", []string{"device", "field"}, nil)
+
+func (testVendorLog) Name() string { return "TestLog" }
+
+func (testVendorLog) Args(drive string) []string { return rawLogArgs(drive, 0xd0, 4) }
+
+func (testVendorLog) Describe(ch chan<- *prometheus.Desc) { ch <- testVendorLogDesc }
+
+func (testVendorLog) Decode(data []byte) ([]VendorMetric, error) {
+	return []VendorMetric{{Desc: testVendorLogDesc, ValueType: prometheus.GaugeValue, Value: leUint(data), LabelValues: []string{"raw"}}}, nil
+}
+
+func TestRegisterVendorLog(t *testing.T) {
+	// 0xffff is not a valid PCI vendor ID, no other log is registered for it
+	RegisterVendorLog(0xffff, testVendorLog{})
+	defer func() {
+		vendorLogsMu.Lock()
+		delete(vendorLogs, 0xffff)
+		vendorLogsMu.Unlock()
+	}()
+
+	mockExecutor := newVendorLogMockExecutor(t, "65535")
+	mockExecutor.Outputs["nvme get-log /dev/nvme0n1 --log-id=0xd0 --log-len=4 --raw-binary"] = "\x2a\x00\x00\x00"
+
+	originalGetNVMeDrives := GetNVMeDrives
+	GetNVMeDrives = mockGetNVMeDrives
+	defer func() { GetNVMeDrives = originalGetNVMeDrives }()
+
+	registry := prometheus.NewRegistry()
+	NewMetrics(mockExecutor, registry, 5*time.Minute)
+
+	expected := `
+# HELP nvme_test_log_value Test log value
+# TYPE nvme_test_log_value gauge
+nvme_test_log_value{device="nvme0n1",field="raw"} 42
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "nvme_test_log_value"); err != nil {
+		t.Fatalf("unexpected collecting result:\n%s", err)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angelhvargas/dell-disk-exporter/pkg/config"
+	"github.com/angelhvargas/dell-disk-exporter/pkg/idrac"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// hostnamePattern matches a DNS host name. Its labels cannot start with "-", so a
+// target cannot be read as a racadm option.
+var hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*\.?$`)
+
+// probeHandler serves /probe?target=<idrac-host>&module=<name>. Every request
+// collects the iDRAC metrics of target into a fresh registry, in the style of
+// the blackbox and snmp exporters. module defaults to "default". The target
+// must be listed in the targets of the module before its credentials are used.
+func probeHandler(w http.ResponseWriter, r *http.Request, conf *config.Config, executor idrac.CommandExecutor) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "Target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	moduleName := r.URL.Query().Get("module")
+	if moduleName == "" {
+		moduleName = "default"
+	}
+	module, ok := conf.Modules[moduleName]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown module %q", moduleName), http.StatusBadRequest)
+		return
+	}
+	host, err := parseTarget(target)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid target %q: %v", target, err), http.StatusBadRequest)
+		return
+	}
+	if !module.Allows(host) {
+		http.Error(w, fmt.Sprintf("Target %q is not allowed by module %q", target, moduleName), http.StatusForbidden)
+		return
+	}
+
+	probeSuccess := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_success",
+		Help: "Whether the iDRAC storage inventory of the target could be read",
+	})
+	probeDuration := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_duration_seconds",
+		Help: "Duration of the probe in seconds",
+	})
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(probeSuccess, probeDuration)
+
+	client := idrac.NewClientWithSource(probeSource(target, module, executor), registry, 0)
+	// Serve the results of the update below instead of collecting again on Gather.
+	client.MinInterval = time.Hour
+	start := time.Now()
+	if err := client.Update(); err != nil {
+		log.Printf("Error probing %s with module %s: %v", target, moduleName, err)
+	} else {
+		probeSuccess.Set(1)
+	}
+	probeDuration.Set(time.Since(start).Seconds())
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// parseTarget checks that target is a host name or IP address with an optional
+// port, and returns its host. IPv6 addresses are written in brackets.
+func parseTarget(target string) (string, error) {
+	host, port := target, ""
+	if h, p, err := net.SplitHostPort(target); err == nil {
+		host, port = h, p
+	} else if strings.HasPrefix(target, "[") && strings.HasSuffix(target, "]") {
+		host = target[1 : len(target)-1]
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.To4() == nil && !strings.HasPrefix(target, "[") {
+			return "", errors.New("IPv6 addresses must be written in brackets")
+		}
+	} else if !hostnamePattern.MatchString(host) || host != target && port == "" {
+		return "", errors.New("expected host[:port]")
+	}
+	if port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("invalid port %q", port)
+		}
+	}
+	return host, nil
+}
+
+// probeSource returns the iDRAC backend described by module for target, a
+// host[:port] checked with parseTarget.
+func probeSource(target string, module config.Module, executor idrac.CommandExecutor) idrac.Source {
+	if module.Source == "racadm" {
+		return idrac.NewRemoteRacadmSource(executor, target, module.Username, module.Password)
+	}
+
+	return idrac.NewRedfishSource(idrac.RedfishConfig{
+		Endpoint:           "https://" + target,
+		Username:           module.Username,
+		Password:           module.Password,
+		InsecureSkipVerify: module.InsecureSkipVerify,
+		Timeout:            module.Timeout,
+	})
+}
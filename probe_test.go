@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/angelhvargas/dell-disk-exporter/pkg/config"
+)
+
+var probeConfig = &config.Config{
+	Modules: map[string]config.Module{
+		"default": {Source: "racadm", Username: "root", Password: "calvin", Targets: []string{"idrac.example.com", "10.0.0.0/24"}},
+	},
+}
+
+func TestProbeHandler(t *testing.T) {
+	mockExecutor := &MockCommandExecutor{
+		MockOutput: `
+Disk.Virtual.0:RAID.Integrated.1-1
+   Layout                           = Raid-1
+   Status                           = Degraded
+   RemainingRedundancy              = 0
+   Size                             = 372.00 GB
+`,
+	}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/probe?target=idrac.example.com", nil)
+	probeHandler(recorder, request, probeConfig, mockExecutor)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", recorder.Code)
+	}
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		"probe_success 1\n",
+		`raid_status{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 3` + "\n",
+		`raid_redundancy{vdisk="RAID.Integrated.1-1",vdisk_fqdd="Disk.Virtual.0:RAID.Integrated.1-1"} 0` + "\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected %q in response:\n%s", expected, body)
+		}
+	}
+}
+
+func TestProbeHandlerFailure(t *testing.T) {
+	mockExecutor := &MockCommandExecutor{MockError: errors.New("connection refused")}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/probe?target=10.0.0.5:443&module=default", nil)
+	probeHandler(recorder, request, probeConfig, mockExecutor)
+
+	if body := recorder.Body.String(); !strings.Contains(body, "probe_success 0\n") {
+		t.Fatalf("Expected probe_success 0 in response:\n%s", body)
+	}
+}
+
+func TestProbeHandlerInvalidRequest(t *testing.T) {
+	for _, url := range []string{"/probe", "/probe?target=idrac.example.com&module=missing"} {
+		recorder := httptest.NewRecorder()
+		probeHandler(recorder, httptest.NewRequest(http.MethodGet, url, nil), probeConfig, &MockCommandExecutor{})
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", url, recorder.Code)
+		}
+	}
+}
+
+// unusedExecutor fails the test when a command is run.
+type unusedExecutor struct {
+	t *testing.T
+}
+
+func (e *unusedExecutor) ExecuteCommand(name string, args ...string) ([]byte, error) {
+	e.t.Errorf("Unexpected command %s %v", name, args)
+	return nil, errors.New("unexpected command")
+}
+
+func TestProbeHandlerInvalidTarget(t *testing.T) {
+	for _, target := range []string{
+		"-ptest",
+		"--help",
+		"idrac.example.com/redfish",
+		"https://idrac.example.com",
+		"idrac.example.com%20-p",
+		"idrac.example.com:0",
+		"idrac.example.com:",
+		"10.0.0.1:https",
+		"fe80::1",
+	} {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/probe?target="+target, nil)
+		probeHandler(recorder, request, probeConfig, &unusedExecutor{t: t})
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", target, recorder.Code)
+		}
+	}
+}
+
+func TestProbeHandlerTargetNotAllowed(t *testing.T) {
+	for _, target := range []string{"attacker.example.com", "10.0.1.1", "idrac.example.com.attacker.example.com"} {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/probe?target="+target, nil)
+		probeHandler(recorder, request, probeConfig, &unusedExecutor{t: t})
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("%s: expected status 403, got %d", target, recorder.Code)
+		}
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	for target, expected := range map[string]string{
+		"idrac.example.com":     "idrac.example.com",
+		"IDRAC.example.com:443": "IDRAC.example.com",
+		"10.0.0.1":              "10.0.0.1",
+		"10.0.0.1:8443":         "10.0.0.1",
+		"[2001:db8::1]":         "2001:db8::1",
+		"[2001:db8::1]:443":     "2001:db8::1",
+	} {
+		host, err := parseTarget(target)
+		if err != nil || host != expected {
+			t.Errorf("%s: expected %q, got %q, %v", target, expected, host, err)
+		}
+	}
+}